@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	suggestDebounce = 400 * time.Millisecond
+	suggestTTL      = 15 * time.Minute
+	suggestMinLen   = 3
+	suggestResults  = 5
+	suggestMaxCache = 500
+	maxChoices      = 25
+)
+
+type cachedSearch struct {
+	results []SearchResult
+	expires time.Time
+}
+
+// suggestions caches search results for autocomplete and remembers the last
+// query typed by each user, so that only the query a user stopped typing at
+// costs YouTube API quota.
+type suggestions struct {
+	mu     sync.Mutex
+	cache  map[string]cachedSearch
+	latest map[string]string
+}
+
+func newSuggestions() *suggestions {
+	return &suggestions{
+		cache:  make(map[string]cachedSearch),
+		latest: make(map[string]string),
+	}
+}
+
+func (sg *suggestions) cached(query string) ([]SearchResult, bool) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	c, ok := sg.cache[query]
+	if !ok || time.Now().After(c.expires) {
+		return nil, false
+	}
+	return c.results, true
+}
+
+func (sg *suggestions) store(query string, results []SearchResult) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	if len(sg.cache) >= suggestMaxCache {
+		now := time.Now()
+		for q, c := range sg.cache {
+			if now.After(c.expires) {
+				delete(sg.cache, q)
+			}
+		}
+	}
+	if len(sg.cache) >= suggestMaxCache {
+		sg.cache = make(map[string]cachedSearch)
+	}
+
+	sg.cache[query] = cachedSearch{results, time.Now().Add(suggestTTL)}
+}
+
+// settle records query as the user's latest and reports whether it is still
+// the latest once the debounce interval has passed.
+func (sg *suggestions) settle(userID, query string) bool {
+	sg.mu.Lock()
+	sg.latest[userID] = query
+	sg.mu.Unlock()
+
+	time.Sleep(suggestDebounce)
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	if sg.latest[userID] != query {
+		return false
+	}
+	delete(sg.latest, userID)
+	return true
+}
+
+func (ch *CommandHandler) handleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleAutocomplete: "
+
+	var query string
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Focused {
+			query = strings.TrimSpace(opt.StringValue())
+		}
+	}
+
+	choices := ch.historyChoices(query)
+
+	if len(query) >= suggestMinLen && !IsURL(query) {
+		key := strings.ToLower(query)
+
+		results, ok := ch.suggest.cached(key)
+		if !ok && ch.suggest.settle(i.Member.User.ID, key) {
+			var err error
+			results, err = SearchYouTube(query, suggestResults)
+			if err != nil {
+				ch.lg.Error(op+"Error searching: ", err)
+			} else {
+				ch.suggest.store(key, results)
+			}
+		}
+
+		for _, r := range results {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  truncate(fmt.Sprintf("%s (%s)", r.Title, FormatDuration(r.Duration)), 100),
+				Value: WatchURL(r.ID).String(),
+			})
+		}
+	}
+
+	if len(choices) > maxChoices {
+		choices = choices[:maxChoices]
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error responding to interaction: ", err)
+	}
+}
+
+// historyChoices suggests recently played YouTube songs matching query,
+// most recent first.
+func (ch *CommandHandler) historyChoices(query string) []*discordgo.ApplicationCommandOptionChoice {
+	query = strings.ToLower(query)
+	seen := make(map[string]bool)
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	for n := len(ch.history) - 1; n >= 0; n-- {
+		song := ch.history[n].song
+		if song.id == "" || song.mediaURL != "" || seen[song.id] || !strings.Contains(strings.ToLower(song.title), query) {
+			continue
+		}
+		seen[song.id] = true
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  truncate("Recently played: "+song.title, 100),
+			Value: WatchURL(song.id).String(),
+		})
+	}
+
+	return choices
+}
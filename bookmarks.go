@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const bookmarksPath = "./bookmarks.json"
+
+// minBookmark is how far into an episode playback has to get before its
+// position is remembered.
+const minBookmark = 30 * time.Second
+
+// BookmarkStore remembers per guild where podcast episodes stopped, in
+// seconds by episode key.
+type BookmarkStore struct {
+	mu     sync.RWMutex
+	path   string
+	guilds map[string]map[string]int
+}
+
+func LoadBookmarks(path string) (*BookmarkStore, error) {
+	st := &BookmarkStore{path: path, guilds: make(map[string]map[string]int)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading bookmarks: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &st.guilds); err != nil {
+		return nil, fmt.Errorf("error parsing bookmarks: %w", err)
+	}
+
+	return st, nil
+}
+
+// Get returns where the episode stopped, zero if it was not bookmarked.
+func (st *BookmarkStore) Get(guildID, episode string) time.Duration {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	return time.Duration(st.guilds[guildID][episode]) * time.Second
+}
+
+// Set bookmarks the episode at pos, or forgets it if pos is zero.
+func (st *BookmarkStore) Set(guildID, episode string, pos time.Duration) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	seconds := int(pos / time.Second)
+
+	bookmarks, ok := st.guilds[guildID]
+	if !ok {
+		if seconds == 0 {
+			return nil
+		}
+		bookmarks = make(map[string]int)
+		st.guilds[guildID] = bookmarks
+	}
+
+	if seconds == 0 {
+		if _, ok = bookmarks[episode]; !ok {
+			return nil
+		}
+		delete(bookmarks, episode)
+	} else {
+		bookmarks[episode] = seconds
+	}
+
+	data, err := json.MarshalIndent(st.guilds, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding bookmarks: %w", err)
+	}
+
+	if err = writeFileAtomic(st.path, data); err != nil {
+		return fmt.Errorf("error saving bookmarks: %w", err)
+	}
+
+	return nil
+}
+
+// saveBookmark remembers where a podcast episode stopped, or forgets it once
+// the episode played to the end.
+func (ch *CommandHandler) saveBookmark(song *Song, ended bool) {
+	if song.episode == "" {
+		return
+	}
+
+	pos := song.offset
+	if ended || pos < minBookmark {
+		pos = 0
+	}
+
+	if err := ch.bookmarks.Set(song.guildID, song.episode, pos); err != nil {
+		ch.lg.Error("saveBookmark: Error saving bookmark: ", err)
+	}
+}
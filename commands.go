@@ -2,6 +2,18 @@ package main
 
 import "github.com/bwmarrin/discordgo"
 
+var manageGuild int64 = discordgo.PermissionManageGuild
+
+var minVoteRatio = 0.01
+
+var minZero = 0.0
+
+var minLoudness = -30.0
+
+var minBitrateValue float64 = minBitrate
+
+var minRateValue = minRate
+
 var Commands = []*discordgo.ApplicationCommand{
 	// Utility
 	{Name: "join", Description: "Join the voice channel you are in"},
@@ -13,25 +25,407 @@ var Commands = []*discordgo.ApplicationCommand{
 	{Name: "shuffle", Description: "Shuffles the queue"},
 
 	// Music playback
-	{Name: "play", Description: "Play a song from youtube"},
 	{Name: "pause", Description: "Pause the current song"},
 	{Name: "skip", Description: "Skip the current song"},
+	{Name: "previous", Description: "Go back to the previous song"},
+	{Name: "replay", Description: "Restart the current song"},
+	{Name: "history", Description: "Show recently played songs"},
 
 	// Options
 	{Name: "add", Description: "Adds a song to the queue",
 		Options: []*discordgo.ApplicationCommandOption{
 			{
-				Name:        "url",
-				Description: "The URL of the song to add",
-				Type:        discordgo.ApplicationCommandOptionString,
-				Required:    false,
+				Name:         "url",
+				Description:  "The URL of the song to add",
+				Type:         discordgo.ApplicationCommandOptionString,
+				Required:     false,
+				Autocomplete: true,
 			},
 			{
 				Name:        "file",
-				Description: "File of the song to add",
+				Description: "Audio or video file of the song to add",
 				Type:        discordgo.ApplicationCommandOptionAttachment,
 				Required:    false,
 			},
+			{
+				Name:        "file2",
+				Description: "Another file to add",
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+			},
+			{
+				Name:        "file3",
+				Description: "Another file to add",
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+			},
+			{
+				Name:        "file4",
+				Description: "Another file to add",
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+			},
+			{
+				Name:        "file5",
+				Description: "Another file to add",
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+			},
+		}},
+	{Name: "play", Description: "Play a song from youtube",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:         "query",
+				Description:  "Search terms or URL of the song to play",
+				Type:         discordgo.ApplicationCommandOptionString,
+				Required:     true,
+				Autocomplete: true,
+			},
+		}},
+	{Name: "search", Description: "Search youtube and pick a song to add",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "query",
+				Description: "Search terms",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Required:    true,
+			},
+		}},
+	{Name: "config", Description: "Configure the bot for this server",
+		DefaultMemberPermissions: &manageGuild,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "show",
+				Description: "Show the current settings",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+			},
+			{
+				Name:        "dj",
+				Description: "Configure the DJ role and which commands need it",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "role",
+						Description: "The DJ role",
+						Type:        discordgo.ApplicationCommandOptionRole,
+					},
+					{
+						Name:        "clear-role",
+						Description: "Remove the DJ role, leaving DJ rights to Manage Server",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+					},
+					{
+						Name:        "commands",
+						Description: "Comma separated commands that need the DJ role, or none",
+						Type:        discordgo.ApplicationCommandOptionString,
+					},
+					{
+						Name:        "alone-exempt",
+						Description: "Let anyone alone with the bot in voice use DJ commands",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+					},
+					{
+						Name:        "discord-permissions",
+						Description: "Hide DJ commands from members without Manage Server",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+					},
+				},
+			},
+			{
+				Name:        "voteskip",
+				Description: "Configure skipping songs by vote",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "enabled",
+						Description: "Make /skip a vote unless used by the requester or a DJ",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+					},
+					{
+						Name:        "ratio",
+						Description: "Fraction of listeners that must vote, e.g. 0.5",
+						Type:        discordgo.ApplicationCommandOptionNumber,
+						MinValue:    &minVoteRatio,
+						MaxValue:    1,
+					},
+				},
+			},
+			{
+				Name:        "queue",
+				Description: "Configure fair queueing and per user limits",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "fair",
+						Description: "Interleave songs round-robin by requester",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+					},
+					{
+						Name:        "max-user-songs",
+						Description: "Most songs one user can have queued, 0 for no limit",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						MinValue:    &minZero,
+					},
+					{
+						Name:        "max-user-minutes",
+						Description: "Most minutes of songs one user can have queued, 0 for no limit",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						MinValue:    &minZero,
+					},
+				},
+			},
+			{
+				Name:        "limits",
+				Description: "Configure what can be queued",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "max-track-minutes",
+						Description: "Longest song in minutes, 0 for no limit",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						MinValue:    &minZero,
+					},
+					{
+						Name:        "max-queue",
+						Description: "Most songs in the queue, 0 for no limit",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						MinValue:    &minZero,
+					},
+					{
+						Name:        "max-playlist",
+						Description: "Largest playlist that can be added, 0 for no limit",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						MinValue:    &minZero,
+					},
+				},
+			},
+			{
+				Name:        "idle",
+				Description: "Configure leaving voice when idle",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "minutes",
+						Description: "Leave after this long with nothing to play or nobody listening, 0 to stay",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Required:    true,
+						MinValue:    &minZero,
+					},
+				},
+			},
+			{
+				Name:        "normalize",
+				Description: "Configure loudness normalization",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "enabled",
+						Description: "Bring every song to the same loudness",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Required:    true,
+					},
+					{
+						Name:        "target",
+						Description: "Target loudness in LUFS, -16 by default",
+						Type:        discordgo.ApplicationCommandOptionNumber,
+						MinValue:    &minLoudness,
+						MaxValue:    -5,
+					},
+				},
+			},
+			{
+				Name:        "encoding",
+				Description: "Configure the opus encoder",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "bitrate",
+						Description: "Bitrate in kb/s, 64 by default",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						MinValue:    &minBitrateValue,
+						MaxValue:    maxBitrate,
+					},
+					{
+						Name:        "vbr",
+						Description: "Variable bitrate",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "on", Value: "on"},
+							{Name: "off", Value: "off"},
+							{Name: "constrained", Value: "constrained"},
+						},
+					},
+					{
+						Name:        "application",
+						Description: "What the encoder tunes for",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "audio", Value: "audio"},
+							{Name: "voip", Value: "voip"},
+							{Name: "lowdelay", Value: "lowdelay"},
+						},
+					},
+					{
+						Name:        "match-channel",
+						Description: "Use the voice channel's bitrate instead",
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+					},
+				},
+			},
+			{
+				Name:        "crossfade",
+				Description: "Configure mixing songs into each other",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "seconds",
+						Description: "How long songs overlap, 0 to play them back to back",
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Required:    true,
+						MinValue:    &minZero,
+						MaxValue:    maxCrossfade,
+					},
+				},
+			},
+			{
+				Name:        "blocklist",
+				Description: "Block videos, channels or words in titles",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "action",
+						Description: "Add to or remove from the blocklist",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "add", Value: "add"},
+							{Name: "remove", Value: "remove"},
+						},
+					},
+					{
+						Name:        "type",
+						Description: "What to block",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "video", Value: "video"},
+							{Name: "channel", Value: "channel"},
+							{Name: "keyword", Value: "keyword"},
+						},
+					},
+					{
+						Name:        "value",
+						Description: "Video ID or URL, channel ID or name, or keyword",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+				},
+			},
+		}},
+	{Name: "volume", Description: "Shows or changes the playback volume",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "percent",
+				Description: "Volume in percent, 100 plays songs as they are",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				MinValue:    &minZero,
+				MaxValue:    maxVolume,
+			},
+		}},
+	{Name: "filter", Description: "Shows or changes the audio effect",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "preset",
+				Description: "The effect to apply",
+				Type:        discordgo.ApplicationCommandOptionString,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "off", Value: "off"},
+					{Name: "bass boost", Value: "bassboost"},
+					{Name: "nightcore", Value: "nightcore"},
+					{Name: "vaporwave", Value: "vaporwave"},
+					{Name: "8D", Value: "8d"},
+					{Name: "karaoke", Value: "karaoke"},
+				},
+			},
+			{
+				Name:        "speed",
+				Description: "Custom speed, 1 is normal",
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				MinValue:    &minRateValue,
+				MaxValue:    maxRate,
+			},
+			{
+				Name:        "pitch",
+				Description: "Custom pitch, 1 is normal",
+				Type:        discordgo.ApplicationCommandOptionNumber,
+				MinValue:    &minRateValue,
+				MaxValue:    maxRate,
+			},
+		}},
+	{Name: "podcast", Description: "Play podcast episodes",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "add",
+				Description: "Pick recent episodes of a podcast to add",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "feed",
+						Description: "The URL of the RSS or Atom feed",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+				},
+			},
+		}},
+	{Name: "import", Description: "Adds every song of an M3U, PLS or XSPF playlist",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "file",
+				Description: "The playlist file",
+				Type:        discordgo.ApplicationCommandOptionAttachment,
+				Required:    true,
+			},
+		}},
+	{Name: "library", Description: "Play music from the server's library",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "search",
+				Description: "Search the library and pick tracks to add",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:        "query",
+						Description: "Words from the artist, album, title or file name",
+						Type:        discordgo.ApplicationCommandOptionString,
+						Required:    true,
+					},
+				},
+			},
+			{
+				Name:        "play",
+				Description: "Add the best matching track",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         "query",
+						Description:  "Words from the artist, album, title or file name",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Required:     true,
+						Autocomplete: true,
+					},
+				},
+			},
+			{
+				Name:        "album",
+				Description: "Add a whole album in order",
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Name:         "name",
+						Description:  "The album, or words from it or its artist",
+						Type:         discordgo.ApplicationCommandOptionString,
+						Required:     true,
+						Autocomplete: true,
+					},
+				},
+			},
 		}},
 	{Name: "remove", Description: "Removes a song from the queue",
 		Options: []*discordgo.ApplicationCommandOption{
@@ -42,4 +436,38 @@ var Commands = []*discordgo.ApplicationCommand{
 				Required:    true,
 			},
 		}},
+	{Name: "move", Description: "Moves a song to another position in the queue",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "from",
+				Description: "The index of the song to move",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    true,
+			},
+			{
+				Name:        "to",
+				Description: "The index to move the song to",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    true,
+			},
+		}},
+	{Name: "skipto", Description: "Skips to a song, dropping every song before it",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:        "index",
+				Description: "The index of the song to skip to",
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Required:    true,
+			},
+		}},
+	{Name: "playnext", Description: "Adds a song right after the current one",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Name:         "url",
+				Description:  "The URL of the song to add",
+				Type:         discordgo.ApplicationCommandOptionString,
+				Required:     true,
+				Autocomplete: true,
+			},
+		}},
 }
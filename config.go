@@ -0,0 +1,385 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func (ch *CommandHandler) handleConfig(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleConfig: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	if !isAdmin(i.Member) {
+		ch.lg.Error(op + "Not an admin")
+		ch.Error(s, i, errors.New("you need the Manage Server permission"))
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+
+	var update func(settings *Settings) error
+
+	switch sub.Name {
+	case "show":
+		ch.WaitSuccess(s, i, formatSettings(ch.settings.Get(GUILD)))
+		return
+	case "dj":
+		update = func(settings *Settings) error { return configDJ(settings, sub.Options) }
+	case "voteskip":
+		update = func(settings *Settings) error { return configVoteSkip(settings, sub.Options) }
+	case "queue":
+		update = func(settings *Settings) error { return configQueue(settings, sub.Options) }
+	case "limits":
+		update = func(settings *Settings) error { return configLimits(settings, sub.Options) }
+	case "blocklist":
+		update = func(settings *Settings) error { return configBlocklist(settings, sub.Options) }
+	case "idle":
+		update = func(settings *Settings) error {
+			settings.IdleMinutes = int(sub.Options[0].IntValue())
+			return nil
+		}
+	case "normalize":
+		update = func(settings *Settings) error { return configNormalize(settings, sub.Options) }
+	case "encoding":
+		update = func(settings *Settings) error { return configEncoding(settings, sub.Options) }
+	case "crossfade":
+		update = func(settings *Settings) error {
+			settings.CrossfadeSeconds = int(sub.Options[0].IntValue())
+			return nil
+		}
+	default:
+		ch.lg.Error(op + "Unknown subcommand: " + sub.Name)
+		ch.Error(s, i, fmt.Errorf("unknown subcommand: %s", sub.Name))
+		return
+	}
+
+	var updateErr error
+	settings, err := ch.settings.Update(GUILD, func(settings *Settings) {
+		updateErr = update(settings)
+	})
+	if updateErr != nil {
+		ch.Error(s, i, updateErr)
+		return
+	}
+	if err != nil {
+		ch.lg.Error(op+"Error saving settings: ", err)
+		ch.Error(s, i, fmt.Errorf("Error saving settings: %w", err))
+		return
+	}
+
+	if settings.FairQueue {
+		ch.mu.Lock()
+		ch.fairReorder()
+		ch.mu.Unlock()
+	}
+
+	if sub.Name == "normalize" || sub.Name == "encoding" {
+		ch.RestartSource()
+	}
+
+	// Songs queued while normalization was off are measured before they play.
+	if sub.Name == "normalize" {
+		ch.mu.RLock()
+		queue := slices.Clone(ch.queue)
+		ch.mu.RUnlock()
+
+		for _, song := range queue {
+			ch.measureAhead(song)
+		}
+	}
+
+	if err = ch.RegisterCommands(); err != nil {
+		ch.lg.Error(op+"Error registering commands: ", err)
+		ch.Error(s, i, fmt.Errorf("Error registering commands: %w", err))
+		return
+	}
+
+	note := ""
+	if sub.Name == "normalize" {
+		note = ch.sourceNote()
+	}
+
+	ch.WaitSuccess(s, i, formatSettings(settings)+note)
+
+	ch.lg.Info("Successfully updated settings")
+}
+
+// configDJ applies the options of /config dj. Settings are left untouched
+// if any option is invalid.
+func configDJ(settings *Settings, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	next := *settings
+
+	for _, opt := range options {
+		switch opt.Name {
+		case "role":
+			next.DJRole = opt.RoleValue(nil, "").ID
+		case "clear-role":
+			if opt.BoolValue() {
+				next.DJRole = ""
+			}
+		case "commands":
+			names, err := parseCommandNames(opt.StringValue())
+			if err != nil {
+				return err
+			}
+			next.DJCommands = names
+		case "alone-exempt":
+			next.AloneExempt = opt.BoolValue()
+		case "discord-permissions":
+			next.DiscordPermissions = opt.BoolValue()
+		}
+	}
+
+	*settings = next
+	return nil
+}
+
+func configVoteSkip(settings *Settings, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	for _, opt := range options {
+		switch opt.Name {
+		case "enabled":
+			settings.VoteSkip = opt.BoolValue()
+		case "ratio":
+			settings.VoteRatio = opt.FloatValue()
+		}
+	}
+
+	return nil
+}
+
+func configQueue(settings *Settings, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	for _, opt := range options {
+		switch opt.Name {
+		case "fair":
+			settings.FairQueue = opt.BoolValue()
+		case "max-user-songs":
+			settings.MaxUserSongs = int(opt.IntValue())
+		case "max-user-minutes":
+			settings.MaxUserMinutes = int(opt.IntValue())
+		}
+	}
+
+	return nil
+}
+
+func configLimits(settings *Settings, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	for _, opt := range options {
+		switch opt.Name {
+		case "max-track-minutes":
+			settings.MaxTrackMinutes = int(opt.IntValue())
+		case "max-queue":
+			settings.MaxQueueLength = int(opt.IntValue())
+		case "max-playlist":
+			settings.MaxPlaylistSize = int(opt.IntValue())
+		}
+	}
+
+	return nil
+}
+
+func configNormalize(settings *Settings, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	for _, opt := range options {
+		switch opt.Name {
+		case "enabled":
+			settings.Normalize = opt.BoolValue()
+		case "target":
+			settings.LoudnessTarget = opt.FloatValue()
+		}
+	}
+
+	return nil
+}
+
+func configEncoding(settings *Settings, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	for _, opt := range options {
+		switch opt.Name {
+		case "bitrate":
+			settings.Bitrate = int(opt.IntValue())
+		case "vbr":
+			settings.VBR = opt.StringValue()
+		case "application":
+			settings.Application = opt.StringValue()
+		case "match-channel":
+			settings.MatchChannelBitrate = opt.BoolValue()
+		}
+	}
+
+	return nil
+}
+
+func configBlocklist(settings *Settings, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	var action, kind, value string
+
+	for _, opt := range options {
+		switch opt.Name {
+		case "action":
+			action = opt.StringValue()
+		case "type":
+			kind = opt.StringValue()
+		case "value":
+			value = strings.TrimSpace(opt.StringValue())
+		}
+	}
+
+	var list *[]string
+
+	switch kind {
+	case "video":
+		list = &settings.BlockedVideos
+		if IsURL(value) {
+			id, err := videoIDFromURL(value)
+			if err != nil {
+				return err
+			}
+			value = id
+		}
+	case "channel":
+		list = &settings.BlockedChannels
+	case "keyword":
+		list = &settings.BlockedKeywords
+	default:
+		return fmt.Errorf("unknown blocklist type: %s", kind)
+	}
+
+	if value == "" {
+		return errors.New("nothing to block")
+	}
+
+	// Settings are shared with readers, so never modify the list in place.
+	switch action {
+	case "add":
+		if !slices.Contains(*list, value) {
+			*list = append(slices.Clone(*list), value)
+		}
+	case "remove":
+		if !slices.Contains(*list, value) {
+			return fmt.Errorf("%s is not on the blocklist", value)
+		}
+		*list = slices.DeleteFunc(slices.Clone(*list), func(v string) bool { return v == value })
+	default:
+		return fmt.Errorf("unknown blocklist action: %s", action)
+	}
+
+	return nil
+}
+
+func videoIDFromURL(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil || !IsYouTubeURL(u) {
+		return "", fmt.Errorf("invalid YT link: %s", s)
+	}
+
+	if strings.Contains(u.Path, "/playlist") {
+		return "", errors.New("playlists can't be blocked, block their videos instead")
+	}
+
+	ids, err := GetSongID(*u)
+	if err != nil || len(ids) == 0 {
+		return "", fmt.Errorf("invalid YT link: %s", s)
+	}
+
+	return ids[0], nil
+}
+
+func list(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+// limit formats a limit where zero means no limit.
+func limit(n int, unit string) string {
+	if n <= 0 {
+		return "no limit"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// parseCommandNames parses a comma separated list of command names, where
+// "none" means no commands.
+func parseCommandNames(list string) ([]string, error) {
+	names := make([]string, 0)
+
+	if strings.TrimSpace(list) == "none" {
+		return names, nil
+	}
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+		if name == "" {
+			continue
+		}
+
+		known := slices.ContainsFunc(Commands, func(c *discordgo.ApplicationCommand) bool {
+			return c.Name == name
+		})
+		if !known || name == "config" {
+			return nil, fmt.Errorf("unknown command: %s", name)
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func formatSettings(settings Settings) string {
+	b := strings.Builder{}
+
+	role := "none (Manage Server only)"
+	if settings.DJRole != "" {
+		role = fmt.Sprintf("<@&%s>", settings.DJRole)
+	}
+
+	commands := "none"
+	if len(settings.DJCommands) > 0 {
+		commands = "/" + strings.Join(settings.DJCommands, ", /")
+	}
+
+	b.WriteString(fmt.Sprintf("DJ role: %s\n", role))
+	b.WriteString(fmt.Sprintf("DJ commands: %s\n", commands))
+	b.WriteString(fmt.Sprintf("Alone with the bot counts as DJ: %s\n", onOff(settings.AloneExempt)))
+	b.WriteString(fmt.Sprintf("Hide DJ commands with Discord permissions: %s\n", onOff(settings.DiscordPermissions)))
+	b.WriteString(fmt.Sprintf("Vote skip: %s, %.0f%% of listeners\n", onOff(settings.VoteSkip), settings.VoteRatio*100))
+	b.WriteString(fmt.Sprintf("Fair queue: %s\n", onOff(settings.FairQueue)))
+	b.WriteString(fmt.Sprintf("Songs per user: %s\n", limit(settings.MaxUserSongs, "songs")))
+	b.WriteString(fmt.Sprintf("Queued time per user: %s\n", limit(settings.MaxUserMinutes, "minutes")))
+	b.WriteString(fmt.Sprintf("Song length: %s\n", limit(settings.MaxTrackMinutes, "minutes")))
+	b.WriteString(fmt.Sprintf("Queue length: %s\n", limit(settings.MaxQueueLength, "songs")))
+	b.WriteString(fmt.Sprintf("Playlist size: %s\n", limit(settings.MaxPlaylistSize, "songs")))
+	b.WriteString(fmt.Sprintf("Blocked videos: %s\n", list(settings.BlockedVideos)))
+	b.WriteString(fmt.Sprintf("Blocked channels: %s\n", list(settings.BlockedChannels)))
+	b.WriteString(fmt.Sprintf("Blocked words: %s\n", list(settings.BlockedKeywords)))
+	b.WriteString(fmt.Sprintf("Leave voice when idle for: %s\n", limit(settings.IdleMinutes, "minutes")))
+	b.WriteString(fmt.Sprintf("Volume: %d%%\n", settings.Volume))
+	b.WriteString(fmt.Sprintf("Loudness normalization: %s, %.1f LUFS\n", onOff(settings.Normalize), settings.LoudnessTarget))
+	b.WriteString(fmt.Sprintf("Crossfade: %s\n", limit(settings.CrossfadeSeconds, "seconds")))
+
+	bitrate := fmt.Sprintf("%d kb/s", settings.Bitrate)
+	if settings.MatchChannelBitrate {
+		bitrate = "voice channel bitrate"
+	}
+	b.WriteString(fmt.Sprintf("Encoding: %s, VBR %s, %s\n", bitrate, settings.VBR, settings.Application))
+
+	return b.String()
+}
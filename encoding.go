@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	defaultBitrate     = 64
+	minBitrate         = 8
+	maxBitrate         = 510
+	defaultVBR         = "on"
+	defaultApplication = "audio"
+)
+
+// Encoding is the opus encoder profile songs are cached and streamed with.
+// Frames are always 20ms long, as that is what discordgo sends them at.
+type Encoding struct {
+	Bitrate     int
+	VBR         string
+	Application string
+}
+
+var defaultEncoding = Encoding{
+	Bitrate:     defaultBitrate,
+	VBR:         defaultVBR,
+	Application: defaultApplication,
+}
+
+// cachePath returns where the song id is cached with e. The default profile
+// keeps the plain name so existing caches stay valid.
+func (e Encoding) cachePath(id string) string {
+	if e == defaultEncoding {
+		return fmt.Sprintf("audio/%s.dca", id)
+	}
+	return fmt.Sprintf("audio/%s.%dk-%s-%s.dca", id, e.Bitrate, e.VBR, e.Application)
+}
+
+// args returns the ffmpeg output options that encode to opus in Ogg.
+func (e Encoding) args() []string {
+	return []string{
+		// Video, and cover art that ffmpeg reads as video, is dropped.
+		"-vn",
+		"-ar", "48000", "-ac", "2",
+		"-c:a", "libopus",
+		"-b:a", strconv.Itoa(e.Bitrate) + "k",
+		"-vbr", e.VBR,
+		"-application", e.Application,
+		"-frame_duration", "20",
+		// Small pages so the player gets frames as soon as they are encoded.
+		"-page_duration", "20000",
+		"-f", "ogg", "pipe:1",
+	}
+}
+
+func (e Encoding) String() string {
+	return fmt.Sprintf("%d kb/s, VBR %s, %s", e.Bitrate, e.VBR, e.Application)
+}
+
+// encoding returns the profile for the guild, matched to the bitrate of the
+// bot's voice channel if the settings ask for it.
+func (ch *CommandHandler) encoding() Encoding {
+	settings := ch.settings.Get(GUILD)
+
+	e := Encoding{
+		Bitrate:     settings.Bitrate,
+		VBR:         settings.VBR,
+		Application: settings.Application,
+	}
+
+	if settings.MatchChannelBitrate {
+		ch.mu.RLock()
+		channelID := ch.voiceChan
+		ch.mu.RUnlock()
+
+		if c, err := ch.session.State.Channel(channelID); err == nil && c.Bitrate > 0 {
+			e.Bitrate = min(max(c.Bitrate/1000, minBitrate), maxBitrate)
+		}
+	}
+
+	return e
+}
+
+// encodeFile encodes the audio file at path into a dca file at dcaPath.
+func encodeFile(path, dcaPath string, e Encoding) error {
+	args := append([]string{"-v", "error", "-i", path}, e.args()...)
+	cmd := exec.Command("ffmpeg", args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("error creating ffmpeg pipe: %w", err)
+	}
+
+	file, err := os.Create(dcaPath)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer file.Close()
+
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("error starting ffmpeg: %w", err)
+	}
+
+	copyErr := copyFrames(newOggReader(out), file)
+
+	if err = cmd.Wait(); err != nil {
+		_ = os.Remove(dcaPath)
+		return errors.New(err.Error() + ": " + stderr.String())
+	}
+
+	if copyErr != nil {
+		_ = os.Remove(dcaPath)
+		return copyErr
+	}
+
+	return nil
+}
+
+// copyFrames writes every frame of r to w in the dca format.
+func copyFrames(r *oggReader, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for {
+		frame, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = binary.Write(bw, binary.LittleEndian, int16(len(frame))); err != nil {
+			return fmt.Errorf("error writing frame: %w", err)
+		}
+		if _, err = bw.Write(frame); err != nil {
+			return fmt.Errorf("error writing frame: %w", err)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("error writing frames: %w", err)
+	}
+
+	return nil
+}
+
+// oggReader reads the opus packets out of an Ogg stream, skipping the
+// OpusHead and OpusTags header packets.
+type oggReader struct {
+	r       *bufio.Reader
+	packets [][]byte
+	// partial is a packet that continues on the next page.
+	partial []byte
+	headers int
+}
+
+func newOggReader(r io.Reader) *oggReader {
+	return &oggReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next opus packet, or io.EOF at the end of the stream.
+func (o *oggReader) Next() ([]byte, error) {
+	for {
+		for len(o.packets) > 0 {
+			packet := o.packets[0]
+			o.packets = o.packets[1:]
+
+			if o.headers < 2 {
+				o.headers++
+				continue
+			}
+			return packet, nil
+		}
+
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (o *oggReader) readPage() error {
+	// capture pattern, version, header type, granule position, serial
+	// number, sequence number, checksum and segment count.
+	var header [27]byte
+	if _, err := io.ReadFull(o.r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return io.EOF
+		}
+		return err
+	}
+
+	if string(header[:4]) != "OggS" {
+		return errors.New("invalid ogg page")
+	}
+
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(o.r, segments); err != nil {
+		return fmt.Errorf("error reading ogg page: %w", err)
+	}
+
+	for _, size := range segments {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(o.r, data); err != nil {
+			return fmt.Errorf("error reading ogg page: %w", err)
+		}
+
+		o.partial = append(o.partial, data...)
+
+		// A segment shorter than 255 bytes ends the packet.
+		if size < 255 {
+			o.packets = append(o.packets, o.partial)
+			o.partial = nil
+		}
+	}
+
+	return nil
+}
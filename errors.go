@@ -1 +1,38 @@
 package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrNotOwner   = errors.New("only the requester or a DJ can do that")
+	ErrNotInVoice = errors.New("you need to be in a voice channel first")
+)
+
+const maxReasons = 10
+
+// SkippedError lists the songs of a playlist that were not added and why.
+type SkippedError struct {
+	Added   int
+	Reasons []string
+}
+
+func (e *SkippedError) Add(id string, err error) {
+	e.Reasons = append(e.Reasons, fmt.Sprintf("%s: %s", id, err))
+}
+
+func (e *SkippedError) Error() string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("added %d songs, skipped %d:", e.Added, len(e.Reasons)))
+	for n, r := range e.Reasons {
+		// Keep the list short enough for a Discord message.
+		if n == maxReasons {
+			b.WriteString(fmt.Sprintf("\n- and %d more", len(e.Reasons)-n))
+			break
+		}
+		b.WriteString("\n- " + r)
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// fairOrder interleaves songs round-robin by requester, in the order each
+// requester first appears, keeping every requester's own order.
+func fairOrder(songs []*Song) []*Song {
+	users := make([]string, 0)
+	byUser := make(map[string][]*Song)
+
+	for _, song := range songs {
+		if _, ok := byUser[song.requester]; !ok {
+			users = append(users, song.requester)
+		}
+		byUser[song.requester] = append(byUser[song.requester], song)
+	}
+
+	ordered := make([]*Song, 0, len(songs))
+	for round := 0; len(ordered) < len(songs); round++ {
+		for _, user := range users {
+			if round < len(byUser[user]) {
+				ordered = append(ordered, byUser[user][round])
+			}
+		}
+	}
+
+	return ordered
+}
+
+// fairReorder applies fairOrder to the upcoming songs that are not pinned,
+// leaving pinned songs where they were put. ch.mu must be held.
+func (ch *CommandHandler) fairReorder() {
+	start := 0
+	if ch.current != nil && len(ch.queue) > 0 && ch.queue[0] == ch.current {
+		start = 1
+	}
+
+	free := make([]int, 0, len(ch.queue))
+	songs := make([]*Song, 0, len(ch.queue))
+	for i := start; i < len(ch.queue); i++ {
+		if !ch.queue[i].pinned {
+			free = append(free, i)
+			songs = append(songs, ch.queue[i])
+		}
+	}
+
+	for n, song := range fairOrder(songs) {
+		ch.queue[free[n]] = song
+	}
+}
+
+// userLimits reports whether requester may queue another song lasting
+// duration. ch.mu must be held.
+func (ch *CommandHandler) userLimits(requester string, duration time.Duration) error {
+	settings := ch.settings.Get(GUILD)
+	if settings.MaxUserSongs <= 0 && settings.MaxUserMinutes <= 0 {
+		return nil
+	}
+
+	count := 0
+	total := duration
+	for _, song := range ch.queue {
+		if song == ch.current || song.requester != requester {
+			continue
+		}
+		count++
+		total += song.duration
+	}
+
+	if settings.MaxUserSongs > 0 && count >= settings.MaxUserSongs {
+		return fmt.Errorf("you already have %d songs queued, the limit is %d", count, settings.MaxUserSongs)
+	}
+
+	limit := time.Duration(settings.MaxUserMinutes) * time.Minute
+	if settings.MaxUserMinutes > 0 && total > limit {
+		return fmt.Errorf("that would put %s of your songs in the queue, the limit is %s",
+			FormatDuration(total), FormatDuration(limit))
+	}
+
+	return nil
+}
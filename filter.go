@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	minRate = 0.5
+	maxRate = 2.0
+)
+
+// filterPresets are the /filter presets that are plain ffmpeg filters.
+var filterPresets = map[string]string{
+	"bassboost": "bass=g=10:f=110:w=0.6",
+	"8d":        "apulsator=hz=0.125",
+	"karaoke":   "pan=stereo|c0=c0-c1|c1=c1-c0",
+}
+
+// ratePresets are the /filter presets that change speed and pitch together.
+var ratePresets = map[string]float64{
+	"nightcore": 1.25,
+	"vaporwave": 0.8,
+}
+
+// Filter is the effect applied to playback with /filter. Zero speed or pitch
+// means unchanged.
+type Filter struct {
+	preset string
+	speed  float64
+	pitch  float64
+}
+
+// Speed is how fast the song plays compared to normal.
+func (f Filter) Speed() float64 {
+	if f.speed != 0 {
+		return f.speed
+	}
+	if rate, ok := ratePresets[f.preset]; ok {
+		return rate
+	}
+	return 1
+}
+
+// Pitch is how much higher the song sounds compared to normal.
+func (f Filter) Pitch() float64 {
+	if f.pitch != 0 {
+		return f.pitch
+	}
+	if rate, ok := ratePresets[f.preset]; ok {
+		return rate
+	}
+	return 1
+}
+
+// filters returns the ffmpeg filter chain for f.
+func (f Filter) filters() []string {
+	filters := make([]string, 0)
+
+	if preset, ok := filterPresets[f.preset]; ok {
+		filters = append(filters, preset)
+	}
+
+	speed, pitch := f.Speed(), f.Pitch()
+	if pitch != 1 {
+		// Resampling changes the pitch and speed together, atempo below
+		// makes up for the speed.
+		filters = append(filters,
+			"aresample=48000",
+			fmt.Sprintf("asetrate=%d", int(48000*pitch)),
+			"aresample=48000",
+		)
+	}
+	if tempo := speed / pitch; tempo != 1 {
+		filters = append(filters, atempo(tempo)...)
+	}
+
+	return filters
+}
+
+// atempo chains atempo filters, as one only goes from half to double speed.
+func atempo(tempo float64) []string {
+	filters := make([]string, 0)
+	for tempo > maxRate {
+		filters = append(filters, fmt.Sprintf("atempo=%g", maxRate))
+		tempo /= maxRate
+	}
+	for tempo < minRate {
+		filters = append(filters, fmt.Sprintf("atempo=%g", minRate))
+		tempo /= minRate
+	}
+	return append(filters, fmt.Sprintf("atempo=%.4f", tempo))
+}
+
+func (f Filter) String() string {
+	parts := make([]string, 0)
+	if f.preset != "" {
+		parts = append(parts, f.preset)
+	}
+	if f.speed != 0 {
+		parts = append(parts, fmt.Sprintf("speed %.2fx", f.speed))
+	}
+	if f.pitch != 0 {
+		parts = append(parts, fmt.Sprintf("pitch %.2fx", f.pitch))
+	}
+	if len(parts) == 0 {
+		return "off"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (ch *CommandHandler) Filter() Filter {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.filter
+}
+
+// SetFilter changes the effect, continuing the current song from where it is.
+func (ch *CommandHandler) SetFilter(f Filter) {
+	ch.mu.Lock()
+	ch.filter = f
+	ch.mu.Unlock()
+
+	ch.RestartSource()
+}
+
+func (ch *CommandHandler) handleFilter(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleFilter: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		ch.WaitSuccess(s, i, "Filter: "+ch.Filter().String())
+		return
+	}
+
+	// Picking a preset starts over, custom speed and pitch apply on top.
+	f := ch.Filter()
+	for _, opt := range options {
+		if opt.Name == "preset" {
+			f = Filter{preset: opt.StringValue()}
+			if f.preset == "off" {
+				f.preset = ""
+			}
+		}
+	}
+	for _, opt := range options {
+		switch opt.Name {
+		case "speed":
+			f.speed = opt.FloatValue()
+		case "pitch":
+			f.pitch = opt.FloatValue()
+		}
+	}
+
+	if _, ok := filterPresets[f.preset]; f.preset != "" && !ok {
+		if _, ok = ratePresets[f.preset]; !ok {
+			ch.Error(s, i, errors.New("unknown preset: "+f.preset))
+			return
+		}
+	}
+
+	ch.SetFilter(f)
+
+	ch.WaitSuccess(s, i, "Filter: "+f.String()+ch.sourceNote())
+
+	ch.lg.Info("Successfully set filter: %s", f.String())
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -10,34 +11,16 @@ import (
 func (ch *CommandHandler) handleJoin(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	const op string = "handleJoin: "
 
-	c, err := s.State.Channel(i.ChannelID)
-	if err != nil {
-		ch.lg.Error(op+"Error getting channel state: ", err)
-		ch.Error(s, i, fmt.Errorf("Error getting channel state: %w", err))
-		return
-	}
+	ch.Wait(s, i)
 
-	g, err := s.State.Guild(c.GuildID)
+	msg, err := ch.JoinVoice(i.Member)
 	if err != nil {
-		ch.lg.Error(op+"Error getting guild state: ", err)
-		ch.Error(s, i, fmt.Errorf("Error getting guild state: %w", err))
+		ch.lg.Error(op+"Error joining voice channel: ", err)
+		ch.Error(s, i, err)
 		return
 	}
 
-	for _, vs := range g.VoiceStates {
-		if vs.UserID == i.Member.User.ID {
-			ch.voiceConn, err = s.ChannelVoiceJoin(ch.ctx, GUILD, vs.ChannelID, false, false)
-			if err != nil {
-				ch.lg.Error(op+"Error joining voice channel: ", err)
-				ch.Error(s, i, fmt.Errorf("Error joining voice channel: %w", err))
-				return
-			}
-		}
-	}
-
-	ch.inVC = true
-
-	ch.Success(s, i, "Joined")
+	ch.WaitSuccess(s, i, msg)
 
 	ch.lg.Info("Joined voice channel")
 }
@@ -45,24 +28,15 @@ func (ch *CommandHandler) handleJoin(s *discordgo.Session, i *discordgo.Interact
 func (ch *CommandHandler) handleLeave(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	const op string = "handleLeave: "
 
-	err := ch.voiceConn.Speaking(false)
-	if err != nil {
-		ch.lg.Error(op+"Error disabling voice: ", err)
-		ch.Error(s, i, fmt.Errorf("Error disabling voice: %w", err))
-		return
-	}
-
-	ch.isSpeaking = false
+	ch.Wait(s, i)
 
-	if err = ch.voiceConn.Disconnect(ch.ctx); err != nil {
+	if err := ch.LeaveVoice(); err != nil {
 		ch.lg.Error(op+"Error leaving voice channel: ", err)
 		ch.Error(s, i, fmt.Errorf("Error leaving voice channel: %w", err))
 		return
 	}
 
-	ch.inVC = false
-
-	ch.Success(s, i, "Left")
+	ch.WaitSuccess(s, i, "Left")
 
 	ch.lg.Info("Left voice channel")
 }
@@ -84,27 +58,103 @@ func (ch *CommandHandler) handleAdd(s *discordgo.Session, i *discordgo.Interacti
 		return
 	}
 
-	switch i.ApplicationCommandData().Options[0].Name {
-	case "file":
+	// A url and files at once would silently drop one or the other.
+	options := i.ApplicationCommandData().Options
+	hasURL := slices.ContainsFunc(options, func(o *discordgo.ApplicationCommandInteractionDataOption) bool {
+		return o.Name == "url"
+	})
+	if hasURL && len(options) > 1 {
+		ch.Error(s, i, errors.New("add either a url or files, not both"))
+		return
+	}
+
+	msg := "Added to queue"
+
+	switch opt := options[0]; {
+	case opt.Type == discordgo.ApplicationCommandOptionAttachment:
 		err := ch.HandleFileAttachment(s, i)
-		if err != nil {
-			ch.lg.Error(op+"Error downloading attachment: ", err)
-			ch.Error(s, i, fmt.Errorf("Error downloading attachment: %w", err))
+		if skipped, ok := partiallyAdded(err); ok {
+			msg = "Added to queue, " + skipped.Error()
+		} else if err != nil {
+			ch.lg.Error(op+"Error adding attachment: ", err)
+			ch.Error(s, i, fmt.Errorf("Error adding attachment: %w", err))
 			return
 		}
-	case "url":
+	case opt.Name == "url":
 		err := ch.HandleYouTubeURL(s, i)
-		if err != nil {
+		if skipped, ok := partiallyAdded(err); ok {
+			msg = "Added to queue, " + skipped.Error()
+		} else if err != nil {
 			ch.lg.Error(op+"Error adding song: ", err)
 			ch.Error(s, i, fmt.Errorf("Error adding song: %w", err))
 			return
 		}
 	}
 
-	ch.WaitSuccess(s, i, "Added to queue")
+	ch.WaitSuccess(s, i, msg)
+
+	ch.startPlayback(s, i)
+}
+
+func (ch *CommandHandler) handlePlay(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handlePlay: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	if len(i.ApplicationCommandData().Options) == 0 {
+		ch.lg.Error(op + "No options provided")
+		ch.Error(s, i, errors.New("no options provided"))
+		return
+	}
+
+	msg := "Added to queue"
+
+	err := ch.HandleQuery(i.ApplicationCommandData().Options[0].StringValue(), i.Member.User.ID)
+	if skipped, ok := partiallyAdded(err); ok {
+		msg = "Added to queue, " + skipped.Error()
+	} else if err != nil {
+		ch.lg.Error(op+"Error adding song: ", err)
+		ch.Error(s, i, fmt.Errorf("Error adding song: %w", err))
+		return
+	}
+
+	ch.WaitSuccess(s, i, msg)
+
+	ch.startPlayback(s, i)
+}
+
+// partiallyAdded reports whether err only means some songs of a playlist
+// were skipped while others were added.
+func partiallyAdded(err error) (*SkippedError, bool) {
+	var skipped *SkippedError
+	if errors.As(err, &skipped) && skipped.Added > 0 {
+		return skipped, true
+	}
+	return nil, false
+}
+
+// startPlayback joins the caller's voice channel if needed and starts the
+// player, posting the now playing panel in the interaction's channel.
+func (ch *CommandHandler) startPlayback(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	ch.mu.Lock()
+	if ch.current == nil {
+		ch.textChan = i.ChannelID
+	}
+	ch.mu.Unlock()
 
 	if ch.voiceConn == nil && !ch.inVC {
-		ch.handleJoin(s, i)
+		// Joining starts playback.
+		if _, err := ch.JoinVoice(i.Member); err != nil {
+			ch.lg.Error("startPlayback: Error joining voice channel: ", err)
+			ch.Followup(s, i, fmt.Sprintf("Could not join voice: %v", err))
+		}
+		return
 	}
 
 	go ch.PlaySong()
@@ -123,10 +173,11 @@ func (ch *CommandHandler) handleRemove(s *discordgo.Session, i *discordgo.Intera
 
 	index := int(i.ApplicationCommandData().Options[0].IntValue())
 
-	title, err := ch.RemoveSong(index)
+	title, err := ch.RemoveSongAs(index, i.Member)
 	if err != nil {
 		ch.lg.Error(op+"Error removing song from queue: ", err)
 		ch.Error(s, i, fmt.Errorf("Error removing song from queue: %w", err))
+		return
 	}
 
 	ch.WaitSuccess(s, i, "Removed from queue")
@@ -213,10 +264,12 @@ func (ch *CommandHandler) handlePauseResume(s *discordgo.Session, i *discordgo.I
 		ch.PausePlayback()
 		ch.lg.Info("Paused playback")
 		ch.WaitSuccess(s, i, "Paused playback")
+		ch.ShowPanel(true)
 	} else {
 		ch.ResumePlayback()
 		ch.lg.Info("Resumed playback")
 		ch.WaitSuccess(s, i, "Resumed playback")
+		ch.ShowPanel(false)
 	}
 }
 
@@ -243,8 +296,207 @@ func (ch *CommandHandler) handleSkip(s *discordgo.Session, i *discordgo.Interact
 		return
 	}
 
+	if song := ch.GetCurrentSong(); song != nil && !ch.canManage(i.Member, song) {
+		if !ch.settings.Get(GUILD).VoteSkip {
+			ch.lg.Error(op + "Not the requester")
+			ch.Error(s, i, ErrNotOwner)
+			return
+		}
+
+		msg, err := ch.voteSkipMessage(i)
+		if err != nil {
+			ch.Error(s, i, err)
+			return
+		}
+
+		ch.WaitSuccess(s, i, msg)
+		return
+	}
+
 	ch.SkipSong()
 
 	ch.WaitSuccess(s, i, "Skipped")
 	ch.lg.Info("Successfully skipped song")
 }
+
+func (ch *CommandHandler) handleMove(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleMove: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	from := int(options[0].IntValue())
+	to := int(options[1].IntValue())
+
+	title, err := ch.MoveSong(from, to)
+	if err != nil {
+		ch.lg.Error(op+"Error moving song: ", err)
+		ch.Error(s, i, fmt.Errorf("Error moving song: %w", err))
+		return
+	}
+
+	ch.WaitSuccess(s, i, fmt.Sprintf("Moved %s to %d", title, to))
+
+	ch.lg.Info("Successfully moved: %s", title)
+}
+
+func (ch *CommandHandler) handleSkipTo(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleSkipTo: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	if ch.voiceConn == nil {
+		ch.lg.Error(op + "Not in voice channel")
+		ch.Error(s, i, errors.New("not in voice channel"))
+		return
+	}
+
+	index := int(i.ApplicationCommandData().Options[0].IntValue())
+
+	title, err := ch.SkipTo(index, i.Member)
+	if err != nil {
+		ch.lg.Error(op+"Error skipping: ", err)
+		ch.Error(s, i, fmt.Errorf("Error skipping: %w", err))
+		return
+	}
+
+	ch.WaitSuccess(s, i, "Skipped to "+title)
+
+	ch.lg.Info("Successfully skipped to: %s", title)
+}
+
+func (ch *CommandHandler) handlePlayNext(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handlePlayNext: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	title, err := ch.PlayNext(i.ApplicationCommandData().Options[0].StringValue(), i.Member.User.ID)
+	if skipped, ok := partiallyAdded(err); ok {
+		title += ", " + skipped.Error()
+	} else if err != nil {
+		ch.lg.Error(op+"Error adding song: ", err)
+		ch.Error(s, i, fmt.Errorf("Error adding song: %w", err))
+		return
+	}
+
+	ch.WaitSuccess(s, i, "Playing next: "+title)
+
+	ch.lg.Info("Successfully added next: %s", title)
+
+	ch.startPlayback(s, i)
+}
+
+func (ch *CommandHandler) handleHistory(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleHistory: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	ch.WaitSuccess(s, i, ch.GetFormattedHistory())
+
+	ch.lg.Info("Successfully sent history")
+}
+
+func (ch *CommandHandler) handlePrevious(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handlePrevious: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	if ch.voiceConn == nil {
+		ch.lg.Error(op + "Not in voice channel")
+		ch.Error(s, i, errors.New("not in voice channel"))
+		return
+	}
+
+	title, err := ch.PlayPrevious()
+	if err != nil {
+		ch.lg.Error(op+"Error playing previous song: ", err)
+		ch.Error(s, i, fmt.Errorf("Error playing previous song: %w", err))
+		return
+	}
+
+	ch.WaitSuccess(s, i, "Playing previous: "+title)
+
+	ch.lg.Info("Successfully went back to: %s", title)
+}
+
+func (ch *CommandHandler) handleReplay(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleReplay: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	title, err := ch.Replay()
+	if err != nil {
+		ch.lg.Error(op+"Error replaying song: ", err)
+		ch.Error(s, i, fmt.Errorf("Error replaying song: %w", err))
+		return
+	}
+
+	ch.WaitSuccess(s, i, "Replaying: "+title)
+
+	ch.lg.Info("Successfully replayed: %s", title)
+}
+
+func (ch *CommandHandler) handleVolume(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleVolume: "
+
+	ch.Wait(s, i)
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		ch.WaitSuccess(s, i, fmt.Sprintf("Volume is %d%%", ch.settings.Get(GUILD).Volume))
+		return
+	}
+
+	volume := int(options[0].IntValue())
+
+	_, err := ch.settings.Update(GUILD, func(settings *Settings) {
+		settings.Volume = volume
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error saving volume: ", err)
+		ch.Error(s, i, fmt.Errorf("Error saving volume: %w", err))
+		return
+	}
+
+	ch.RestartSource()
+
+	ch.WaitSuccess(s, i, fmt.Sprintf("Volume set to %d%%", volume)+ch.sourceNote())
+
+	ch.lg.Info("Successfully set volume to %d%%", volume)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const maxHistory = 50
+
+type HistoryEntry struct {
+	song   *Song
+	played time.Time
+}
+
+func (ch *CommandHandler) pushHistory(song *Song) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	song.buffer = nil
+
+	if len(ch.history) == maxHistory {
+		ch.history = append(ch.history[:0], ch.history[1:]...)
+	}
+	ch.history = append(ch.history, HistoryEntry{song, time.Now()})
+}
+
+// PlayPrevious puts the last played song back at the head of the queue and
+// starts it, keeping the interrupted song right after it.
+func (ch *CommandHandler) PlayPrevious() (string, error) {
+	ch.mu.Lock()
+	if len(ch.history) == 0 {
+		ch.mu.Unlock()
+		return "", errors.New("no previous song")
+	}
+
+	prev := ch.history[len(ch.history)-1].song
+	ch.history = ch.history[:len(ch.history)-1]
+	ch.queue = append([]*Song{prev}, ch.queue...)
+	playing := ch.current != nil
+	ch.requeue = playing
+	ch.mu.Unlock()
+
+	if playing {
+		ch.SkipSong()
+	} else {
+		go ch.PlaySong()
+	}
+
+	return prev.title, nil
+}
+
+// Replay restarts the current song from the beginning.
+func (ch *CommandHandler) Replay() (string, error) {
+	ch.mu.Lock()
+	song := ch.current
+	if song == nil {
+		ch.mu.Unlock()
+		return "", errors.New("nothing is playing")
+	}
+
+	if len(ch.queue) == 0 || ch.queue[0] != song {
+		ch.queue = append([]*Song{song}, ch.queue...)
+	}
+	ch.requeue = true
+	ch.mu.Unlock()
+
+	ch.SkipSong()
+
+	return song.title, nil
+}
+
+func (ch *CommandHandler) GetFormattedHistory() string {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	if len(ch.history) == 0 {
+		return "Nothing played yet"
+	}
+
+	b := strings.Builder{}
+	b.WriteString("Recently played:\n")
+
+	for n := len(ch.history) - 1; n >= 0; n-- {
+		e := ch.history[n]
+		line := fmt.Sprintf("%d. %s (<%s>) <t:%d:R>", len(ch.history)-n, e.song.title, e.song.Source(), e.played.Unix())
+		if e.song.requester != "" {
+			line += fmt.Sprintf(" by <@%s>", e.song.requester)
+		}
+		line += "\n"
+
+		// Stay within Discord's message length limit.
+		if b.Len()+len(line) > 2000 {
+			break
+		}
+		b.WriteString(line)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// armIdle starts the countdown to leaving voice, unless it is already running
+// or disabled.
+func (ch *CommandHandler) armIdle() {
+	minutes := ch.settings.Get(GUILD).IdleMinutes
+	if minutes <= 0 {
+		return
+	}
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.idleTimer != nil || ch.voiceConn == nil {
+		return
+	}
+
+	ch.idleTimer = time.AfterFunc(time.Duration(minutes)*time.Minute, ch.idleLeave)
+}
+
+func (ch *CommandHandler) disarmIdle() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.idleTimer != nil {
+		ch.idleTimer.Stop()
+		ch.idleTimer = nil
+	}
+}
+
+// idleLeave leaves voice once the idle timeout passes, as long as there is
+// still nothing to play or nobody to play it to.
+func (ch *CommandHandler) idleLeave() {
+	ch.mu.Lock()
+	ch.idleTimer = nil
+	ch.mu.Unlock()
+
+	listening := len(ch.listeners(GUILD, ch.botChannel(GUILD))) > 0
+	if listening && ch.IsPlaying() {
+		return
+	}
+
+	if err := ch.LeaveVoice(); err != nil {
+		ch.lg.Error("idleLeave: Error leaving voice channel: ", err)
+		return
+	}
+
+	ch.lg.Info("Left voice channel after being idle")
+
+	ch.notify("Left the voice channel due to inactivity")
+}
+
+// LeaveVoice stops playback, clears the queue and leaves the voice channel.
+func (ch *CommandHandler) LeaveVoice() error {
+	ch.Stop()
+	ch.waitIdle()
+	ch.disarmIdle()
+	ch.RemovePanel()
+
+	ch.mu.Lock()
+	vc := ch.voiceConn
+	ch.voiceConn = nil
+	ch.inVC = false
+	ch.autoPaused = false
+	ch.mu.Unlock()
+
+	if vc == nil {
+		return nil
+	}
+
+	if err := vc.Disconnect(ch.ctx); err != nil {
+		return fmt.Errorf("error disconnecting: %w", err)
+	}
+
+	return nil
+}
+
+// waitIdle gives the player a moment to stop after a skip.
+func (ch *CommandHandler) waitIdle() {
+	for range 40 {
+		ch.mu.RLock()
+		running := ch.running
+		ch.mu.RUnlock()
+
+		if !running {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// handleVoiceStateUpdate pauses playback when everyone leaves the bot's voice
+// channel and resumes it when someone comes back.
+func (ch *CommandHandler) handleVoiceStateUpdate(_ *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	if v.GuildID != GUILD {
+		return
+	}
+
+	ch.trackBotVoice(v)
+
+	channelID := ch.botChannel(GUILD)
+	if channelID == "" {
+		return
+	}
+
+	alone := len(ch.listeners(GUILD, channelID)) == 0
+
+	ch.mu.Lock()
+	playing := ch.current != nil
+	pause := alone && playing && ch.isSpeaking && !ch.autoPaused
+	resume := !alone && ch.autoPaused
+	if pause {
+		ch.autoPaused = true
+	}
+	if resume {
+		ch.autoPaused = false
+		resume = playing && !ch.isSpeaking
+	}
+	ch.mu.Unlock()
+
+	switch {
+	case pause:
+		ch.PausePlayback()
+		ch.ShowPanel(true)
+		ch.lg.Info("Paused playback, everyone left")
+	case resume:
+		ch.ResumePlayback()
+		ch.ShowPanel(false)
+		ch.lg.Info("Resumed playback, someone came back")
+	}
+
+	if alone {
+		ch.armIdle()
+	} else if playing {
+		ch.disarmIdle()
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxImportSize is the largest playlist file that is read.
+const maxImportSize = 1 << 20
+
+// PlaylistEntry is a line of an imported playlist.
+type PlaylistEntry struct {
+	// Line is where the entry is in the file, or its number in PLS and XSPF.
+	Line     int
+	Location string
+	Title    string
+}
+
+// Name is how the entry is shown when it could not be added.
+func (e PlaylistEntry) Name() string {
+	if e.Title != "" {
+		return fmt.Sprintf("%d. %s (%s)", e.Line, e.Title, e.Location)
+	}
+	return fmt.Sprintf("%d. %s", e.Line, e.Location)
+}
+
+// parsePlaylistFile parses an M3U, PLS or XSPF playlist, told apart by the
+// file extension or else by its content.
+func parsePlaylistFile(name string, data []byte) ([]PlaylistEntry, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	head := strings.ToLower(string(data[:min(len(data), 512)]))
+
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case ext == ".xspf" || strings.Contains(head, "<playlist"):
+		return parseXSPF(data)
+	case ext == ".pls" || strings.HasPrefix(strings.TrimSpace(head), "[playlist]"):
+		return parsePLS(data)
+	default:
+		return parseM3U(data), nil
+	}
+}
+
+// parseM3U reads one location per line, titled by the #EXTINF line before
+// it if there is one.
+func parseM3U(data []byte) []PlaylistEntry {
+	entries := make([]PlaylistEntry, 0)
+	title := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			// #EXTINF:<seconds> <attributes>,<title>
+			if _, t, ok := strings.Cut(line, ","); ok {
+				title = strings.TrimSpace(t)
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			entries = append(entries, PlaylistEntry{Line: n, Location: line, Title: title})
+			title = ""
+		}
+	}
+
+	return entries
+}
+
+// parsePLS reads the FileN and TitleN keys, ordered by N.
+func parsePLS(data []byte) ([]PlaylistEntry, error) {
+	byNumber := make(map[int]*PlaylistEntry)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		field, number := "", ""
+		switch {
+		case strings.HasPrefix(key, "file"):
+			field, number = "file", strings.TrimPrefix(key, "file")
+		case strings.HasPrefix(key, "title"):
+			field, number = "title", strings.TrimPrefix(key, "title")
+		default:
+			continue
+		}
+
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+
+		e, ok := byNumber[n]
+		if !ok {
+			e = &PlaylistEntry{Line: n}
+			byNumber[n] = e
+		}
+
+		if field == "file" {
+			e.Location = strings.TrimSpace(value)
+		} else {
+			e.Title = strings.TrimSpace(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading playlist: %w", err)
+	}
+
+	entries := make([]PlaylistEntry, 0, len(byNumber))
+	for _, e := range byNumber {
+		if e.Location != "" {
+			entries = append(entries, *e)
+		}
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].Line < entries[b].Line })
+
+	return entries, nil
+}
+
+// parseXSPF reads the first location of every track.
+func parseXSPF(data []byte) ([]PlaylistEntry, error) {
+	var playlist struct {
+		Tracks []struct {
+			Locations []string `xml:"location"`
+			Title     string   `xml:"title"`
+			Creator   string   `xml:"creator"`
+		} `xml:"trackList>track"`
+	}
+
+	if err := xml.Unmarshal(data, &playlist); err != nil {
+		return nil, fmt.Errorf("error parsing XSPF: %w", err)
+	}
+
+	entries := make([]PlaylistEntry, 0, len(playlist.Tracks))
+	for n, t := range playlist.Tracks {
+		e := PlaylistEntry{Line: n + 1, Title: strings.TrimSpace(t.Title)}
+		if creator := strings.TrimSpace(t.Creator); creator != "" && e.Title != "" {
+			e.Title = creator + " - " + e.Title
+		}
+		if len(t.Locations) > 0 {
+			e.Location = strings.TrimSpace(t.Locations[0])
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// AddEntry adds a playlist entry, URLs the way /add does and file paths from
+// the library.
+func (ch *CommandHandler) AddEntry(e PlaylistEntry, requester string) error {
+	if e.Location == "" {
+		return errors.New("no location")
+	}
+
+	location := e.Location
+	if u, err := url.Parse(location); err == nil && u.Scheme == "file" {
+		location = u.Path
+	} else if IsURL(location) && (u.Scheme == "http" || u.Scheme == "https") {
+		return ch.AddURL(location, requester)
+	}
+
+	if ch.library == nil {
+		return errNoLibrary
+	}
+
+	t, ok := ch.library.Resolve(location)
+	if !ok {
+		return errors.New("not in the library")
+	}
+
+	_, err := ch.AddTrack(t, requester)
+	return err
+}
+
+// Resolve finds the track at a path from a playlist, which is either within
+// the library root or relative to it.
+func (l *Library) Resolve(p string) (Track, bool) {
+	p = strings.ReplaceAll(p, `\`, "/")
+
+	if filepath.IsAbs(p) {
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return Track{}, false
+		}
+		p = rel
+	}
+
+	return l.Track(path.Clean(p))
+}
+
+// fetchPlaylistFile downloads an attached playlist.
+func fetchPlaylistFile(a *discordgo.MessageAttachment) ([]byte, error) {
+	if a.Size > maxImportSize {
+		return nil, fmt.Errorf("%s is over %d KB", a.Filename, maxImportSize>>10)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, a.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading playlist: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading playlist: %s", res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxImportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error downloading playlist: %w", err)
+	}
+	if len(data) > maxImportSize {
+		return nil, fmt.Errorf("%s is over %d KB", a.Filename, maxImportSize>>10)
+	}
+
+	return data, nil
+}
+
+func (ch *CommandHandler) handleImport(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleImport: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		ch.lg.Error(op + "No options provided")
+		ch.Error(s, i, errors.New("no options provided"))
+		return
+	}
+
+	attachmentID, _ := data.Options[0].Value.(string)
+	a, ok := data.Resolved.Attachments[attachmentID]
+	if !ok {
+		ch.Error(s, i, errors.New("no file attached"))
+		return
+	}
+
+	raw, err := fetchPlaylistFile(a)
+	if err != nil {
+		ch.lg.Error(op+"Error downloading playlist: ", err)
+		ch.Error(s, i, err)
+		return
+	}
+
+	entries, err := parsePlaylistFile(a.Filename, raw)
+	if err != nil {
+		ch.lg.Error(op+"Error parsing playlist: ", err)
+		ch.Error(s, i, err)
+		return
+	}
+
+	if len(entries) == 0 {
+		ch.Error(s, i, fmt.Errorf("no songs in %s", a.Filename))
+		return
+	}
+
+	if err = ch.checkPlaylistSize(len(entries)); err != nil {
+		ch.Error(s, i, err)
+		return
+	}
+
+	// Entries are added one after another to keep their order.
+	skipped := &SkippedError{}
+	for _, e := range entries {
+		if err = ch.AddEntry(e, i.Member.User.ID); err != nil {
+			ch.lg.Error(op+"Error adding entry: ", err)
+			skipped.Add(e.Name(), err)
+		}
+	}
+
+	skipped.Added = len(entries) - len(skipped.Reasons)
+
+	switch {
+	case skipped.Added == 0:
+		ch.Error(s, i, fmt.Errorf("nothing could be imported: %w", skipped))
+		return
+	case len(skipped.Reasons) > 0:
+		ch.WaitSuccess(s, i, "Imported "+a.Filename+", "+skipped.Error())
+	default:
+		ch.WaitSuccess(s, i, fmt.Sprintf("Imported %s: %d songs", a.Filename, skipped.Added))
+	}
+
+	ch.lg.Info("Successfully imported %d songs from %s", skipped.Added, a.Filename)
+
+	ch.startPlayback(s, i)
+}
@@ -10,6 +10,18 @@ var (
 	GUILD string
 	APP   string
 	YT    string
+	DJ    string
+	// LIBRARY is the directory of local music, empty if there is none.
+	LIBRARY string
+)
+
+var (
+	tokenFlag   = flag.String("token", "", "Your Discord bot token")
+	guildFlag   = flag.String("guild", "", "Guild ID where the bot operates")
+	appFlag     = flag.String("app", "", "Application ID for Discord bot")
+	ytFlag      = flag.String("yt", "", "YouTube API Key")
+	djFlag      = flag.String("dj", "", "Default DJ role ID, see /config dj")
+	libraryFlag = flag.String("library", "", "Directory of local music for /library")
 )
 
 func init() {
@@ -17,16 +29,17 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+}
 
-	tokenFlag := flag.String("token", "", "Your Discord bot token")
-	guildFlag := flag.String("guild", "", "Guild ID where the bot operates")
-	appFlag := flag.String("app", "", "Application ID for Discord bot")
-	ytFlag := flag.String("yt", "", "YouTube API Key")
-
+// parseFlags reads the command line. It runs from main rather than init, as
+// go test parses its own flags.
+func parseFlags() {
 	flag.Parse()
 
 	TOKEN = *tokenFlag
 	GUILD = *guildFlag
 	APP = *appFlag
 	YT = *ytFlag
+	DJ = *djFlag
+	LIBRARY = *libraryFlag
 }
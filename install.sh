@@ -1,13 +1,5 @@
 #!/bin/bash
 
-if ! command -v dca &>/dev/null; then
-    echo "dca is not installed. Installing..."
-    go install -y github.com/bwmarrin/dca/cmd/dca@latest
-    echo "dca installed successfully!"
-else
-    echo "dca is already installed. Skipping..."
-fi
-
 if ! command -v yt-dlp &>/dev/null; then
     echo "yt-dlp is not installed. Installing..."
     sudo add-apt-repository -y ppa:tomtomtom/yt-dlp
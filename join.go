@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// JoinVoice joins the voice channel member is in and returns a message for
+// them. A queue left behind by a lost connection starts playing again.
+func (ch *CommandHandler) JoinVoice(member *discordgo.Member) (string, error) {
+	c, perms, err := ch.checkJoin(member)
+	if err != nil {
+		return "", err
+	}
+
+	ch.mu.Lock()
+	old := ch.voiceConn
+	same := old != nil && ch.voiceChan == c.ID
+	if !same {
+		// The player gives up on old instead of reconnecting to it, or takes
+		// over the new connection when it is resumed after a pause.
+		ch.voiceConn = nil
+	}
+	ch.mu.Unlock()
+
+	if same {
+		return fmt.Sprintf("Already in <#%s>", c.ID), nil
+	}
+
+	if old != nil {
+		if err = old.Disconnect(ch.ctx); err != nil {
+			ch.lg.Error("JoinVoice: Error leaving previous channel: ", err)
+		}
+		ch.waitIdle()
+	}
+
+	vc, err := ch.session.ChannelVoiceJoin(ch.ctx, GUILD, c.ID, false, false)
+	if err != nil {
+		ch.mu.Lock()
+		ch.inVC = false
+		ch.mu.Unlock()
+		return "", fmt.Errorf("error joining <#%s>: %w", c.ID, err)
+	}
+
+	ch.mu.Lock()
+	ch.voiceConn = vc
+	ch.voiceChan = c.ID
+	ch.inVC = true
+	ch.mu.Unlock()
+
+	msg := fmt.Sprintf("Joined <#%s>", c.ID)
+	if c.Type == discordgo.ChannelTypeGuildStageVoice {
+		msg, err = ch.stageSpeak(c, perms)
+		if err != nil {
+			ch.lg.Error("JoinVoice: Error becoming a speaker: ", err)
+			msg = fmt.Sprintf("Joined <#%s>, but could not become a speaker: %v", c.ID, err)
+		}
+	}
+
+	ch.armIdle()
+
+	if !ch.IsPlaying() && !ch.IsEmpty() {
+		go ch.PlaySong()
+	}
+
+	return msg, nil
+}
+
+// checkJoin finds the voice channel member is in and makes sure the bot may
+// join it. It returns the bot's permissions in that channel.
+func (ch *CommandHandler) checkJoin(member *discordgo.Member) (*discordgo.Channel, int64, error) {
+	channelID := ch.userChannel(GUILD, member.User.ID)
+	if channelID == "" {
+		return nil, 0, ErrNotInVoice
+	}
+
+	c, err := ch.session.State.Channel(channelID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting channel state: %w", err)
+	}
+
+	botChannel := ch.botChannel(GUILD)
+	if botChannel != "" && botChannel != channelID && !ch.canMoveBot(member) {
+		if len(ch.listeners(GUILD, botChannel)) > 0 {
+			return nil, 0, fmt.Errorf("I'm already in use in <#%s>, only a DJ can move me", botChannel)
+		}
+	}
+
+	perms, err := ch.session.State.UserChannelPermissions(ch.session.State.User.ID, channelID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting permissions: %w", err)
+	}
+
+	if perms&discordgo.PermissionViewChannel == 0 || perms&discordgo.PermissionVoiceConnect == 0 {
+		return nil, 0, fmt.Errorf("I don't have permission to connect to <#%s>", channelID)
+	}
+
+	if c.Type == discordgo.ChannelTypeGuildStageVoice {
+		return c, perms, nil
+	}
+
+	if perms&discordgo.PermissionVoiceSpeak == 0 {
+		return nil, 0, fmt.Errorf("I don't have permission to speak in <#%s>", channelID)
+	}
+
+	full := c.UserLimit > 0 && ch.occupants(GUILD, channelID) >= c.UserLimit
+	if full && botChannel != channelID && perms&discordgo.PermissionVoiceMoveMembers == 0 {
+		return nil, 0, fmt.Errorf("<#%s> is full (%d/%d)", channelID, c.UserLimit, c.UserLimit)
+	}
+
+	return c, perms, nil
+}
+
+// canMoveBot reports whether member may pull the bot out of a channel other
+// people are listening in.
+func (ch *CommandHandler) canMoveBot(member *discordgo.Member) bool {
+	if isAdmin(member) || member.Permissions&discordgo.PermissionVoiceMoveMembers != 0 {
+		return true
+	}
+
+	role := ch.settings.Get(GUILD).DJRole
+	return role != "" && ch.isDJ(member)
+}
+
+// stageSpeak makes the bot a speaker on a stage, or asks to become one when
+// it may not invite itself.
+func (ch *CommandHandler) stageSpeak(c *discordgo.Channel, perms int64) (string, error) {
+	data := map[string]any{"channel_id": c.ID}
+
+	msg := fmt.Sprintf("Joined the stage <#%s>", c.ID)
+	if perms&discordgo.PermissionVoiceMuteMembers != 0 {
+		data["suppress"] = false
+	} else {
+		if perms&discordgo.PermissionVoiceRequestToSpeak == 0 {
+			return "", fmt.Errorf("I don't have permission to request to speak in <#%s>", c.ID)
+		}
+		data["request_to_speak_timestamp"] = time.Now().UTC().Format(time.RFC3339)
+		msg = fmt.Sprintf("Joined the stage <#%s> and requested to speak, a stage moderator needs to invite me", c.ID)
+	}
+
+	endpoint := discordgo.EndpointGuild(c.GuildID) + "/voice-states/@me"
+	if _, err := ch.session.RequestWithBucketID(http.MethodPatch, endpoint, data, endpoint); err != nil {
+		return "", fmt.Errorf("error updating voice state: %w", err)
+	}
+
+	return msg, nil
+}
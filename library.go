@@ -0,0 +1,610 @@
+package main
+
+import (
+	"cmp"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	libraryPrefix  = "library:"
+	rescanInterval = 30 * time.Minute
+)
+
+var (
+	errNoLibrary = errors.New("there is no music library, start the bot with -library")
+	errNoAudio   = errors.New("no audio stream")
+)
+
+// libraryExts are the files scanned into the library.
+var libraryExts = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+	".ogg":  true,
+	".opus": true,
+	".m4a":  true,
+	".aac":  true,
+	".wav":  true,
+}
+
+// Track is a file in the music library.
+type Track struct {
+	// Path is relative to the library root, with forward slashes.
+	Path     string
+	Title    string
+	Artist   string
+	Album    string
+	Disc     int
+	Number   int
+	Duration time.Duration
+	size     int64
+	modTime  time.Time
+}
+
+// Name is how the track is shown, its artist and title.
+func (t Track) Name() string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	return t.Artist + " - " + t.Title
+}
+
+// id names the cache files of the track, changing whenever the file does.
+func (t Track) id() string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d|%d", t.Path, t.size, t.modTime.UnixNano())))
+	return "library-" + hex.EncodeToString(sum[:8])
+}
+
+// matches reports whether every word of the query is in the track's tags or
+// path.
+func (t Track) matches(words []string) bool {
+	text := strings.ToLower(strings.Join([]string{t.Artist, t.Album, t.Title, t.Path}, " "))
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// Library indexes the music files under root by their tags.
+type Library struct {
+	mu     sync.RWMutex
+	lg     *logger
+	root   string
+	tracks map[string]Track
+}
+
+func NewLibrary(logger *logger, root string) *Library {
+	return &Library{lg: logger, root: root, tracks: make(map[string]Track)}
+}
+
+// Watch scans the library now and then every interval.
+func (l *Library) Watch(interval time.Duration) {
+	for {
+		if err := l.Scan(); err != nil {
+			l.lg.Error("Library: Error scanning: ", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Scan updates the index, probing only files that are new or changed.
+func (l *Library) Scan() error {
+	l.mu.RLock()
+	old := l.tracks
+	l.mu.RUnlock()
+
+	tracks := make(map[string]Track, len(old))
+
+	err := filepath.WalkDir(l.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			l.lg.Error("Library: Error reading "+path+": ", err)
+			return nil
+		}
+		if d.IsDir() || !libraryExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			l.lg.Error("Library: Error reading "+path+": ", err)
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if t, ok := old[rel]; ok && t.size == info.Size() && t.modTime.Equal(info.ModTime()) {
+			tracks[rel] = t
+			return nil
+		}
+
+		t, err := probeTrack(path)
+		if err != nil {
+			l.lg.Error("Library: Error probing "+path+": ", err)
+			return nil
+		}
+
+		t.Path = rel
+		t.size = info.Size()
+		t.modTime = info.ModTime()
+		if t.Title == "" {
+			t.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+
+		tracks[rel] = t
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error scanning %s: %w", l.root, err)
+	}
+
+	l.mu.Lock()
+	l.tracks = tracks
+	l.mu.Unlock()
+
+	l.lg.Info("Library: Indexed %d tracks", len(tracks))
+
+	return nil
+}
+
+// probeTrack reads the tags and duration of the audio file at path.
+func probeTrack(path string) (Track, error) {
+	cmd := exec.Command(
+		"ffprobe", "-v", "error", "-select_streams", "a:0",
+		"-show_entries", "format=duration:format_tags:stream=codec_type:stream_tags", "-of", "json", path,
+	)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return Track{}, fmt.Errorf("error probing file: %w", err)
+	}
+
+	var probe struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType string            `json:"codec_type"`
+			Tags      map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	if err = json.Unmarshal(out, &probe); err != nil {
+		return Track{}, fmt.Errorf("error parsing ffprobe output: %w", err)
+	}
+
+	if len(probe.Streams) == 0 {
+		return Track{}, errNoAudio
+	}
+
+	// Tag names differ in case between formats, and Ogg keeps them on the
+	// stream rather than the container.
+	tags := make(map[string]string)
+	for _, s := range probe.Streams {
+		for k, v := range s.Tags {
+			tags[strings.ToLower(k)] = strings.TrimSpace(v)
+		}
+	}
+	for k, v := range probe.Format.Tags {
+		tags[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+
+	t := Track{
+		Title:  tags["title"],
+		Artist: tags["artist"],
+		Album:  tags["album"],
+		Disc:   tagNumber(cmp.Or(tags["disc"], tags["discnumber"])),
+		Number: tagNumber(cmp.Or(tags["track"], tags["tracknumber"])),
+	}
+	if t.Artist == "" {
+		t.Artist = tags["album_artist"]
+	}
+
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		t.Duration = time.Duration(seconds * float64(time.Second))
+	}
+
+	return t, nil
+}
+
+// tagNumber parses track and disc tags, which may look like 3/12.
+func tagNumber(s string) int {
+	s, _, _ = strings.Cut(s, "/")
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
+
+// sortTracks orders tracks by artist, album and their place on it.
+func sortTracks(tracks []Track) {
+	sort.Slice(tracks, func(a, b int) bool {
+		ta, tb := tracks[a], tracks[b]
+		switch {
+		case !strings.EqualFold(ta.Artist, tb.Artist):
+			return strings.ToLower(ta.Artist) < strings.ToLower(tb.Artist)
+		case !strings.EqualFold(ta.Album, tb.Album):
+			return strings.ToLower(ta.Album) < strings.ToLower(tb.Album)
+		case ta.Disc != tb.Disc:
+			return ta.Disc < tb.Disc
+		case ta.Number != tb.Number:
+			return ta.Number < tb.Number
+		}
+		return ta.Path < tb.Path
+	})
+}
+
+// Search returns up to limit tracks matching every word of query.
+func (l *Library) Search(query string, limit int) []Track {
+	words := strings.Fields(strings.ToLower(query))
+
+	l.mu.RLock()
+	results := make([]Track, 0)
+	for _, t := range l.tracks {
+		if t.matches(words) {
+			results = append(results, t)
+		}
+	}
+	l.mu.RUnlock()
+
+	sortTracks(results)
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Track returns the track at path, relative to the library root.
+func (l *Library) Track(path string) (Track, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	t, ok := l.tracks[filepath.ToSlash(filepath.Clean(path))]
+	return t, ok
+}
+
+// TrackByID returns the track whose id is id, as used by autocomplete.
+func (l *Library) TrackByID(id string) (Track, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, t := range l.tracks {
+		if t.id() == id {
+			return t, true
+		}
+	}
+	return Track{}, false
+}
+
+// albumKey is a short stable autocomplete value for an album, whose name may
+// be too long for Discord.
+func albumKey(album string) string {
+	sum := sha1.Sum([]byte(strings.ToLower(album)))
+	return "album-" + hex.EncodeToString(sum[:8])
+}
+
+// albumByKey returns the album whose albumKey is key, or "".
+func (l *Library) albumByKey(key string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, t := range l.tracks {
+		if t.Album != "" && albumKey(t.Album) == key {
+			return t.Album
+		}
+	}
+	return ""
+}
+
+// Albums returns up to limit album names matching every word of query.
+func (l *Library) Albums(query string, limit int) []string {
+	words := strings.Fields(strings.ToLower(query))
+
+	l.mu.RLock()
+	seen := make(map[string]bool)
+	albums := make([]string, 0)
+	for _, t := range l.tracks {
+		key := strings.ToLower(t.Album)
+		if t.Album == "" || seen[key] || !t.matches(words) {
+			continue
+		}
+		seen[key] = true
+		albums = append(albums, t.Album)
+	}
+	l.mu.RUnlock()
+
+	sort.Slice(albums, func(a, b int) bool {
+		return strings.ToLower(albums[a]) < strings.ToLower(albums[b])
+	})
+
+	if len(albums) > limit {
+		albums = albums[:limit]
+	}
+	return albums
+}
+
+// Album returns the tracks of the album named like query or keyed by it in
+// order, or of the first album matching it.
+func (l *Library) Album(query string) (string, []Track) {
+	name := l.albumByKey(query)
+	for _, album := range l.Albums(query, math.MaxInt) {
+		if strings.EqualFold(album, strings.TrimSpace(query)) {
+			name = album
+			break
+		}
+		if name == "" {
+			name = album
+		}
+	}
+	if name == "" {
+		return "", nil
+	}
+
+	l.mu.RLock()
+	tracks := make([]Track, 0)
+	for _, t := range l.tracks {
+		if strings.EqualFold(t.Album, name) {
+			tracks = append(tracks, t)
+		}
+	}
+	l.mu.RUnlock()
+
+	sortTracks(tracks)
+
+	return name, tracks
+}
+
+// AddTrack encodes a library track like any other song and queues it.
+func (ch *CommandHandler) AddTrack(t Track, requester string) (string, error) {
+	song, err := ch.LoadTrack(t, requester)
+	if err != nil {
+		return "", err
+	}
+
+	if err = ch.EnqueueSong(song); err != nil {
+		return "", err
+	}
+
+	return song.title, nil
+}
+
+// LoadTrack makes a song from a library track, encoding it unless it is
+// already cached. The file itself is the source audio.
+func (ch *CommandHandler) LoadTrack(t Track, requester string) (*Song, error) {
+	if err := ch.checkContent(SearchResult{Title: t.Name(), Channel: t.Artist, Duration: t.Duration}); err != nil {
+		return nil, err
+	}
+
+	if err := ch.checkQueueLimits(requester, t.Duration); err != nil {
+		return nil, err
+	}
+
+	id := t.id()
+	path := filepath.Join(ch.library.root, filepath.FromSlash(t.Path))
+	enc := ch.encoding()
+	audioPath := enc.cachePath(id)
+
+	if _, err := os.Stat(audioPath); err != nil {
+		if err = encodeFile(path, audioPath, enc); err != nil {
+			return nil, fmt.Errorf("error encoding %s: %w", t.Path, err)
+		}
+	}
+
+	song := NewSong(t.Name(), "", audioPath, requester, t.Duration)
+	song.sourcePath = path
+	song.track = t.Path
+	// Loudness is kept with the cache, the library is not ours to write to.
+	song.loudnessFile = fmt.Sprintf("audio/%s.json", id)
+
+	return song, nil
+}
+
+func (ch *CommandHandler) handleLibrary(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleLibrary: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	if ch.library == nil {
+		ch.Error(s, i, errNoLibrary)
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+	query := strings.TrimSpace(sub.Options[0].StringValue())
+
+	switch sub.Name {
+	case "search":
+		ch.librarySearch(s, i, query)
+	case "play":
+		ch.libraryPlay(s, i, query)
+	case "album":
+		ch.libraryAlbum(s, i, query)
+	default:
+		ch.lg.Error(op + "Unknown subcommand: " + sub.Name)
+		ch.Error(s, i, errors.New("unknown subcommand: "+sub.Name))
+	}
+}
+
+// librarySearch lists the tracks matching query to pick from.
+func (ch *CommandHandler) librarySearch(s *discordgo.Session, i *discordgo.InteractionCreate, query string) {
+	const op string = "librarySearch: "
+
+	tracks := ch.library.Search(query, searchResults)
+	if len(tracks) == 0 {
+		ch.Error(s, i, fmt.Errorf("nothing in the library matches: %s", query))
+		return
+	}
+
+	options := make([]discordgo.SelectMenuOption, 0, len(tracks))
+	b := strings.Builder{}
+
+	for n, t := range tracks {
+		details := FormatDuration(t.Duration)
+		if t.Album != "" {
+			details = t.Album + " | " + details
+		}
+
+		b.WriteString(fmt.Sprintf("%d. %s (%s)\n", n+1, t.Name(), details))
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       truncate(fmt.Sprintf("%d. %s", n+1, t.Name()), 100),
+			Value:       strconv.Itoa(n),
+			Description: truncate(details, 100),
+		})
+	}
+
+	minValues := 1
+	menu := discordgo.SelectMenu{
+		Placeholder: "Pick tracks to add",
+		MinValues:   &minValues,
+		MaxValues:   len(options),
+		Options:     options,
+	}
+
+	err := ch.showPicker(s, i, libraryPrefix, b.String(), menu, func(s *discordgo.Session, ci *discordgo.InteractionCreate) {
+		picked := make([]Track, 0)
+		for _, v := range ci.MessageComponentData().Values {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 && n < len(tracks) {
+				picked = append(picked, tracks[n])
+			}
+		}
+
+		err := s.InteractionRespond(ci.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    fmt.Sprintf("Adding %d tracks", len(picked)),
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		if err != nil {
+			ch.lg.Error(op+"Error responding to interaction: ", err)
+		}
+
+		ch.addTracks(s, ci, picked, fmt.Sprintf("%d tracks", len(picked)))
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error sending results: ", err)
+	}
+}
+
+// libraryPlay adds the track picked from autocomplete or at the path query,
+// or else the first one matching it.
+func (ch *CommandHandler) libraryPlay(s *discordgo.Session, i *discordgo.InteractionCreate, query string) {
+	t, ok := ch.library.TrackByID(query)
+	if !ok {
+		t, ok = ch.library.Track(query)
+	}
+	if !ok {
+		tracks := ch.library.Search(query, 1)
+		if len(tracks) == 0 {
+			ch.Error(s, i, fmt.Errorf("nothing in the library matches: %s", query))
+			return
+		}
+		t = tracks[0]
+	}
+
+	ch.addTracks(s, i, []Track{t}, t.Name())
+}
+
+func (ch *CommandHandler) libraryAlbum(s *discordgo.Session, i *discordgo.InteractionCreate, query string) {
+	name, tracks := ch.library.Album(query)
+	if len(tracks) == 0 {
+		ch.Error(s, i, fmt.Errorf("no album in the library matches: %s", query))
+		return
+	}
+
+	ch.addTracks(s, i, tracks, fmt.Sprintf("%s (%d tracks)", name, len(tracks)))
+}
+
+// addTracks queues tracks in order and starts playing, reporting the tracks
+// that could not be added.
+func (ch *CommandHandler) addTracks(s *discordgo.Session, i *discordgo.InteractionCreate, tracks []Track, title string) {
+	const op string = "addTracks: "
+
+	skipped := &SkippedError{}
+	for _, t := range tracks {
+		if _, err := ch.AddTrack(t, i.Member.User.ID); err != nil {
+			ch.lg.Error(op+"Error adding track: ", err)
+			skipped.Add(t.Path, err)
+			continue
+		}
+		ch.lg.Info("Successfully added: %s", t.Path)
+	}
+
+	skipped.Added = len(tracks) - len(skipped.Reasons)
+
+	switch {
+	case skipped.Added == 0:
+		ch.Error(s, i, fmt.Errorf("nothing could be added: %w", skipped))
+		return
+	case len(skipped.Reasons) > 0:
+		ch.WaitSuccess(s, i, "Added to queue, "+skipped.Error())
+	default:
+		ch.WaitSuccess(s, i, "Added to queue: "+title)
+	}
+
+	ch.startPlayback(s, i)
+}
+
+// handleLibraryAutocomplete suggests tracks for /library play and albums
+// for /library album.
+func (ch *CommandHandler) handleLibraryAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleLibraryAutocomplete: "
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+
+	sub := i.ApplicationCommandData().Options[0]
+	if ch.library != nil && len(sub.Options) > 0 {
+		query := strings.TrimSpace(sub.Options[0].StringValue())
+
+		switch sub.Name {
+		case "play":
+			for _, t := range ch.library.Search(query, maxChoices) {
+				// Values are capped at 100 characters, so tracks go by id.
+				choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+					Name:  truncate(fmt.Sprintf("%s (%s)", t.Name(), FormatDuration(t.Duration)), 100),
+					Value: t.id(),
+				})
+			}
+		case "album":
+			for _, album := range ch.library.Albums(query, maxChoices) {
+				choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+					Name:  truncate(album, 100),
+					Value: albumKey(album),
+				})
+			}
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error responding to interaction: ", err)
+	}
+}
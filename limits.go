@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// checkContent rejects songs over the length limit or on the blocklist.
+// Attachments have no ID or channel, so only their title and length are checked.
+func (ch *CommandHandler) checkContent(info SearchResult) error {
+	settings := ch.settings.Get(GUILD)
+
+	if info.ID != "" && slices.Contains(settings.BlockedVideos, info.ID) {
+		return fmt.Errorf("%s is blocked on this server", info.Title)
+	}
+
+	for _, c := range settings.BlockedChannels {
+		if info.ChannelID == c || (info.Channel != "" && strings.EqualFold(info.Channel, c)) {
+			return fmt.Errorf("songs from %s are blocked on this server", info.Channel)
+		}
+	}
+
+	title := strings.ToLower(info.Title)
+	for _, keyword := range settings.BlockedKeywords {
+		if strings.Contains(title, strings.ToLower(keyword)) {
+			return fmt.Errorf("%s contains the blocked word %q", info.Title, keyword)
+		}
+	}
+
+	limit := time.Duration(settings.MaxTrackMinutes) * time.Minute
+	if settings.MaxTrackMinutes > 0 && info.Duration > limit {
+		return fmt.Errorf("%s is %s long, the limit is %s",
+			info.Title, FormatDuration(info.Duration), FormatDuration(limit))
+	}
+
+	return nil
+}
+
+// checkQueueLimits rejects songs once the queue or the requester's share of
+// it is full.
+func (ch *CommandHandler) checkQueueLimits(requester string, duration time.Duration) error {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	return ch.queueLimits(requester, duration)
+}
+
+// queueLimits is checkQueueLimits for callers that hold ch.mu.
+func (ch *CommandHandler) queueLimits(requester string, duration time.Duration) error {
+	settings := ch.settings.Get(GUILD)
+
+	if settings.MaxQueueLength > 0 && len(ch.queue) >= settings.MaxQueueLength {
+		return fmt.Errorf("the queue is full, the limit is %d songs", settings.MaxQueueLength)
+	}
+
+	return ch.userLimits(requester, duration)
+}
+
+func (ch *CommandHandler) checkPlaylistSize(size int) error {
+	settings := ch.settings.Get(GUILD)
+
+	if settings.MaxPlaylistSize > 0 && size > settings.MaxPlaylistSize {
+		return fmt.Errorf("the playlist has %d songs, the limit is %d", size, settings.MaxPlaylistSize)
+	}
+
+	return nil
+}
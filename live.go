@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxStreamRetries = 5
+	streamBackoff    = time.Second
+	probeTimeout     = 15 * time.Second
+)
+
+var (
+	errNotLive = errors.New("only YouTube links can be added as songs, this is not a live stream")
+	errSkipped = errors.New("skipped")
+)
+
+// NewStream creates a song that plays the live stream at streamURL until it
+// is skipped, without caching anything on disk.
+func NewStream(title, streamURL, requester string) *Song {
+	song := NewSong(title, "", "", requester, 0)
+	song.live = true
+	song.mediaURL = streamURL
+	return song
+}
+
+// probeStream asks yt-dlp whether u is live and what it is called. When
+// yt-dlp cannot tell, as with direct radio streams and audio files, u is
+// taken as live only if ffprobe finds no duration.
+func probeStream(u string) (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "yt-dlp", "--no-playlist", "--skip-download",
+		"--print", "%(is_live)s", "--print", "%(title)s", "--print", "%(duration)s", u)
+
+	out, err := cmd.Output()
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if err != nil || len(lines) < 3 {
+		return streamName(u), !hasDuration(u)
+	}
+
+	title := lines[1]
+	if title == "" || title == "NA" {
+		title = streamName(u)
+	}
+
+	switch {
+	case lines[0] == "True":
+		return title, true
+	case lines[0] == "False" || lines[2] != "NA":
+		return title, false
+	default:
+		return title, !hasDuration(u)
+	}
+}
+
+// hasDuration reports whether ffprobe finds a length for the media at u,
+// which endless streams do not have.
+func hasDuration(u string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error",
+		"-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", u)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+
+	d, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	return err == nil && d > 0
+}
+
+// streamName names a stream without a title after its URL.
+func streamName(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return parsed.Host + parsed.Path
+}
+
+// resolveStream returns the media URL ffmpeg can read the stream at u from.
+func resolveStream(u string) string {
+	cmd := exec.Command("yt-dlp", "--no-playlist", "-g", "-f", "bestaudio/best", u)
+
+	out, err := cmd.Output()
+	if err != nil {
+		// Most likely a plain radio stream ffmpeg can read as is.
+		return u
+	}
+
+	media, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if media == "" {
+		return u
+	}
+
+	return media
+}
+
+// startStream starts encoding the live stream of song through filters.
+func startStream(song *Song, filters []string, e Encoding) (*pipeSource, error) {
+	args := []string{
+		"-reconnect", "1", "-reconnect_streamed", "1", "-reconnect_delay_max", "5",
+		"-i", resolveStream(song.mediaURL),
+	}
+	if len(filters) > 0 {
+		args = append(args, "-af", strings.Join(filters, ","))
+	}
+
+	return startEncoder(args, 1, e)
+}
+
+// AddStream adds the live stream or internet radio at streamURL.
+func (ch *CommandHandler) AddStream(streamURL, requester string) (string, error) {
+	title, live := probeStream(streamURL)
+	if !live {
+		return "", errNotLive
+	}
+
+	if err := ch.checkContent(SearchResult{Title: title}); err != nil {
+		return "", err
+	}
+
+	if err := ch.EnqueueSong(NewStream(title, streamURL, requester)); err != nil {
+		return "", err
+	}
+
+	return title, nil
+}
+
+// reopenStream restarts a live stream that stopped, waiting longer after
+// every attempt. It reports false once it gives up, and errSkipped if the
+// song was skipped while waiting.
+func (ch *CommandHandler) reopenStream(song *Song, p *player, attempt int) (bool, error) {
+	if attempt >= maxStreamRetries {
+		ch.lg.Error(fmt.Sprintf("reopenStream: Gave up on %s after %d attempts", song.title, attempt))
+		ch.notify(fmt.Sprintf("Lost the stream %s", song.title))
+		return false, nil
+	}
+
+	ch.lg.Info("Stream %s stopped, reconnecting", song.title)
+
+	select {
+	case <-time.After(streamBackoff << attempt):
+	case <-ch.skipChan:
+		return false, errSkipped
+	case <-ch.ctx.Done():
+		return false, ch.ctx.Err()
+	}
+
+	_ = p.src.Close()
+	p.src = ch.startSource(song)
+
+	return true, nil
+}
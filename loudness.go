@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultLoudnessTarget = -16.0
+	// maxTruePeak keeps normalized songs from clipping.
+	maxTruePeak = -1.0
+	// silence is the quietest loudness loudnorm measures.
+	silence = -70.0
+)
+
+var errNoSource = errors.New("no source audio")
+
+// Loudness is what the first pass of ffmpeg's loudnorm filter measured for a
+// song, stored next to its cache entry.
+type Loudness struct {
+	InputI       float64 `json:"input_i"`
+	InputTP      float64 `json:"input_tp"`
+	InputLRA     float64 `json:"input_lra"`
+	InputThresh  float64 `json:"input_thresh"`
+	TargetOffset float64 `json:"target_offset"`
+}
+
+// Gain returns the gain in dB that brings the song to target LUFS without
+// its true peak going over maxTruePeak.
+func (l *Loudness) Gain(target float64) float64 {
+	if math.IsNaN(l.InputI) || l.InputI <= silence {
+		return 0
+	}
+
+	gain := target - l.InputI
+	if l.InputTP+gain > maxTruePeak {
+		gain = maxTruePeak - l.InputTP
+	}
+
+	return gain
+}
+
+// loudnessPath returns where the loudness of the audio at sourcePath is
+// stored.
+func loudnessPath(sourcePath string) string {
+	return strings.TrimSuffix(sourcePath, filepath.Ext(sourcePath)) + ".json"
+}
+
+// measureLoudness runs the EBU R128 analysis of loudnorm over the file at
+// path.
+func measureLoudness(path string) (*Loudness, error) {
+	cmd := exec.Command(
+		"ffmpeg", "-hide_banner", "-nostats", "-i", path,
+		"-af", "loudnorm=print_format=json", "-f", "null", "-",
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.New(err.Error() + ": " + stderr.String())
+	}
+
+	// The measurements are the last JSON object ffmpeg prints.
+	out := stderr.String()
+	start := strings.LastIndex(out, "{")
+	end := strings.LastIndex(out, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("no loudness measurements in ffmpeg output")
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(out[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("error parsing loudness measurements: %w", err)
+	}
+
+	var l Loudness
+	for key, dst := range map[string]*float64{
+		"input_i":       &l.InputI,
+		"input_tp":      &l.InputTP,
+		"input_lra":     &l.InputLRA,
+		"input_thresh":  &l.InputThresh,
+		"target_offset": &l.TargetOffset,
+	} {
+		v, err := strconv.ParseFloat(raw[key], 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", key, err)
+		}
+		*dst = v
+	}
+
+	return &l, nil
+}
+
+func saveLoudness(jsonPath string, l *Loudness) error {
+	// JSON has no infinity, which silence measures as.
+	clean := *l
+	for _, v := range []*float64{&clean.InputI, &clean.InputTP, &clean.InputLRA, &clean.InputThresh} {
+		if math.IsInf(*v, 0) || math.IsNaN(*v) {
+			*v = silence
+		}
+	}
+
+	data, err := json.Marshal(clean)
+	if err != nil {
+		return fmt.Errorf("error encoding loudness: %w", err)
+	}
+
+	if err = os.WriteFile(jsonPath, data, 0o644); err != nil {
+		return fmt.Errorf("error saving loudness: %w", err)
+	}
+
+	return nil
+}
+
+// storedLoudness loads the loudness stored at jsonPath, measuring the audio
+// at sourcePath if there is none yet.
+func storedLoudness(sourcePath, jsonPath string) (*Loudness, error) {
+	data, err := os.ReadFile(jsonPath)
+	if errors.Is(err, os.ErrNotExist) {
+		if _, err = os.Stat(sourcePath); err != nil {
+			return nil, errNoSource
+		}
+
+		var l *Loudness
+		if l, err = measureLoudness(sourcePath); err != nil {
+			return nil, err
+		}
+		if err = saveLoudness(jsonPath, l); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading loudness: %w", err)
+	}
+
+	var l Loudness
+	if err = json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("error parsing loudness: %w", err)
+	}
+
+	return &l, nil
+}
@@ -10,6 +10,8 @@ import (
 func main() {
 	var err error
 
+	parseFlags()
+
 	lg := NewLogger()
 
 	session, err := discordgo.New("Bot " + TOKEN)
@@ -18,31 +20,73 @@ func main() {
 		os.Exit(1)
 	}
 
-	ch := NewCommandHandler(lg)
-
-	var handlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
-		"join":    ch.handleJoin,
-		"leave":   ch.handleLeave,
-		"add":     ch.handleAdd,
-		"remove":  ch.handleRemove,
-		"pause":   ch.handlePauseResume,
-		"queue":   ch.handleQueue,
-		"shuffle": ch.handleShuffle,
-		"skip":    ch.handleSkip,
-		"clear":   ch.handleClear,
+	settings, err := LoadSettings(settingsPath)
+	if err != nil {
+		lg.Error("could not load settings: ", err)
+		os.Exit(1)
+	}
+
+	bookmarks, err := LoadBookmarks(bookmarksPath)
+	if err != nil {
+		lg.Error("could not load bookmarks: ", err)
+		os.Exit(1)
+	}
+
+	var library *Library
+	if LIBRARY != "" {
+		library = NewLibrary(lg, LIBRARY)
+		go library.Watch(rescanInterval)
+	}
+
+	router := NewRouter(lg)
+
+	ch := NewCommandHandler(lg, session, router, settings, bookmarks, library)
+
+	var handlers = map[string]HandlerFunc{
+		"join":     ch.handleJoin,
+		"leave":    ch.handleLeave,
+		"add":      ch.handleAdd,
+		"play":     ch.handlePlay,
+		"search":   ch.handleSearch,
+		"remove":   ch.handleRemove,
+		"pause":    ch.handlePauseResume,
+		"queue":    ch.handleQueue,
+		"shuffle":  ch.handleShuffle,
+		"skip":     ch.handleSkip,
+		"clear":    ch.handleClear,
+		"move":     ch.handleMove,
+		"skipto":   ch.handleSkipTo,
+		"playnext": ch.handlePlayNext,
+		"previous": ch.handlePrevious,
+		"replay":   ch.handleReplay,
+		"history":  ch.handleHistory,
+		"config":   ch.handleConfig,
+		"volume":   ch.handleVolume,
+		"filter":   ch.handleFilter,
+		"podcast":  ch.handlePodcast,
+		"library":  ch.handleLibrary,
+		"import":   ch.handleImport,
 	}
 
 	session.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		lg.Info("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
 	})
 
-	session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if h, ok := handlers[i.ApplicationCommandData().Name]; ok {
-			h(s, i)
-		}
-	})
+	for name, h := range handlers {
+		router.HandleCommand(name, ch.requireDJ(name, h))
+	}
+
+	router.HandleAutocomplete("add", ch.handleAutocomplete)
+	router.HandleAutocomplete("play", ch.handleAutocomplete)
+	router.HandleAutocomplete("playnext", ch.handleAutocomplete)
+	router.HandleAutocomplete("library", ch.handleLibraryAutocomplete)
+
+	router.HandleComponent(panelPrefix, ch.handlePanel)
+
+	session.AddHandler(router.Route)
+	session.AddHandler(ch.handleVoiceStateUpdate)
 
-	_, err = session.ApplicationCommandBulkOverwrite(APP, GUILD, Commands)
+	err = ch.RegisterCommands()
 	if err != nil {
 		lg.Error("Could not register commands: %s", err)
 		os.Exit(1)
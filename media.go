@@ -3,17 +3,22 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"google.golang.org/api/option"
 	"google.golang.org/api/youtube/v3"
 )
@@ -23,6 +28,21 @@ func IsYouTubeURL(u *url.URL) bool {
 	return normalizedHost == "www.youtube.com" || normalizedHost == "youtube.com" || normalizedHost == "youtu.be"
 }
 
+// IsURL reports whether s looks like an absolute URL rather than search terms.
+func IsURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func WatchURL(id string) *url.URL {
+	return &url.URL{
+		Scheme:   "https",
+		Host:     "www.youtube.com",
+		Path:     "/watch",
+		RawQuery: url.Values{"v": {id}}.Encode(),
+	}
+}
+
 func GetSongID(u url.URL) ([]string, error) {
 	var err error
 	var ids []string
@@ -38,85 +58,199 @@ func GetSongID(u url.URL) ([]string, error) {
 	case strings.Contains(u.Path, "/shorts/"): // shorts yt link
 		ids = append(ids, strings.Split(u.Path, "/shorts/")[1])
 	default: // shorten yt link
-		ids = append(ids, u.Path)
+		ids = append(ids, strings.TrimPrefix(u.Path, "/"))
 	}
 
 	return ids, nil
 }
 
-func GetSongTitle(id string) (string, error) {
+func GetSongInfo(id string) (SearchResult, error) {
 	service, err := youtube.NewService(
 		context.Background(),
 		option.WithAPIKey(YT),
 	)
 	if err != nil {
-		return "", fmt.Errorf("error creating yt service: %w", err)
+		return SearchResult{}, fmt.Errorf("error creating yt service: %w", err)
 	}
-	call := service.Videos.List([]string{"snippet"})
+	call := service.Videos.List([]string{"snippet", "contentDetails"})
 	call = call.Id(id)
 	resp, err := call.Do()
 	if err != nil {
-		return "", fmt.Errorf("error getting playlist data: %w", err)
+		return SearchResult{}, fmt.Errorf("error getting playlist data: %w", err)
 	}
-	var title string
+	info := SearchResult{ID: id}
 	for _, video := range resp.Items {
-		title = video.Snippet.Title
+		info.Title = video.Snippet.Title
+		info.Channel = video.Snippet.ChannelTitle
+		info.ChannelID = video.Snippet.ChannelId
+		info.Duration = parseISODuration(video.ContentDetails.Duration)
+		info.Live = video.Snippet.LiveBroadcastContent == "live"
 	}
 
-	return title, nil
+	return info, nil
 }
 
-func DownloadSong(url url.URL, id string) (string, error) {
-	if err := downloadAudio(url, id); err != nil {
-		return "", fmt.Errorf("error downloading audio: %w", err)
+// probeDuration reads the duration of a local media file with ffprobe.
+func probeDuration(path string) (time.Duration, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "csv=p=0", path)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("error probing file: %w", err)
 	}
 
-	if err := convertToDCA(id); err != nil {
-		return "", fmt.Errorf("error converting to dca: %w", err)
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing duration: %w", err)
 	}
 
-	return fmt.Sprintf("audio/%s.dca", id), nil
+	return time.Duration(seconds * float64(time.Second)), nil
 }
 
-func convertToDCA(id string) error {
-	audioPath := fmt.Sprintf("audio/%s.opus", id)
-	dacPath := fmt.Sprintf("audio/%s.dca", id)
+type SearchResult struct {
+	ID        string
+	Title     string
+	Channel   string
+	ChannelID string
+	Duration  time.Duration
+	Live      bool
+}
 
-	cmdString := fmt.Sprintf("ffmpeg -i %s -f s16le -ar 48000 -ac 2 pipe:1 | ./dca > %s", audioPath, dacPath)
-	cmd := exec.Command("sh", "-c", cmdString)
+func SearchYouTube(query string, limit int64) ([]SearchResult, error) {
+	service, err := youtube.NewService(
+		context.Background(),
+		option.WithAPIKey(YT),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating yt service: %w", err)
+	}
 
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
+	call := service.Search.List([]string{"snippet"})
+	call = call.Q(query).Type("video").MaxResults(limit)
+	resp, err := call.Do()
+	if err != nil {
+		return nil, fmt.Errorf("error searching videos: %w", err)
+	}
 
-	err := cmd.Run()
+	results := make([]SearchResult, 0, len(resp.Items))
+	ids := make([]string, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		results = append(results, SearchResult{
+			ID:        item.Id.VideoId,
+			Title:     item.Snippet.Title,
+			Channel:   item.Snippet.ChannelTitle,
+			ChannelID: item.Snippet.ChannelId,
+		})
+		ids = append(ids, item.Id.VideoId)
+	}
+
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	details, err := service.Videos.List([]string{"contentDetails"}).Id(ids...).Do()
 	if err != nil {
-		return errors.New(err.Error() + ": " + stderr.String())
+		return nil, fmt.Errorf("error getting video details: %w", err)
 	}
 
-	if err = os.Remove(audioPath); err != nil {
-		return fmt.Errorf("error removing audio file: %w", err)
+	durations := make(map[string]time.Duration, len(details.Items))
+	for _, video := range details.Items {
+		durations[video.Id] = parseISODuration(video.ContentDetails.Duration)
 	}
 
-	return nil
+	for i := range results {
+		results[i].Duration = durations[results[i].ID]
+	}
+
+	return results, nil
+}
+
+// parseISODuration parses YouTube's ISO 8601 durations such as PT1H4M13S.
+// Anything it does not understand counts as zero.
+func parseISODuration(s string) time.Duration {
+	s = strings.TrimPrefix(s, "P")
+
+	var d time.Duration
+	var n int
+	inTime := false
+
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			n = n*10 + int(r-'0')
+			continue
+		case r == 'T':
+			inTime = true
+		case r == 'D':
+			d += time.Duration(n) * 24 * time.Hour
+		case r == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0
+		}
+		n = 0
+	}
+
+	return d
+}
+
+// FormatDuration formats d as m:ss or h:mm:ss.
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	sec := int(d % time.Minute / time.Second)
+
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+	}
+	return fmt.Sprintf("%d:%02d", m, sec)
+}
+
+// DownloadSong makes sure the song id is cached with the encoding e,
+// downloading it unless its source audio is still around.
+func DownloadSong(url url.URL, id string, e Encoding) (string, error) {
+	if _, err := os.Stat(sourcePath(id)); err != nil {
+		if err = downloadAudio(url, id); err != nil {
+			return "", fmt.Errorf("error downloading audio: %w", err)
+		}
+	}
+
+	if err := convertToDCA(id, e); err != nil {
+		return "", fmt.Errorf("error converting to dca: %w", err)
+	}
+
+	return e.cachePath(id), nil
+}
+
+// sourcePath is where the audio downloaded for the song id is kept.
+func sourcePath(id string) string {
+	return fmt.Sprintf("audio/%s.opus", id)
+}
+
+func convertToDCA(id string, e Encoding) error {
+	audioPath := sourcePath(id)
+
+	// The source stays next to the cache for processing during playback.
+	return encodeFile(audioPath, e.cachePath(id), e)
 }
 
 func downloadAudio(url url.URL, id string) error {
-	var cmdString string
-	if strings.Contains(url.String(), "playlist") {
-		cmdString = fmt.Sprintf(
-			`yt-dlp -x "https://www.youtube.com/watch?v=%s" --recode-video opus -o audio/%s`,
-			id, id,
-		)
-	} else {
-		cmdString = fmt.Sprintf(
-			`yt-dlp -x "%s" --recode-video opus -o audio/%s`,
-			url.String(), id,
-		)
+	youTube := IsYouTubeURL(&url)
+
+	source := url.String()
+	if youTube && strings.Contains(source, "playlist") {
+		source = WatchURL(id).String()
 	}
 
-	cmd := exec.Command("sh", "-c", cmdString)
+	// No shell, as URLs from podcast feeds are not ours to trust. The audio
+	// format makes files that are audio already end up as opus as well.
+	cmd := exec.Command("yt-dlp", "-x", source,
+		"--audio-format", "opus", "--recode-video", "opus", "-o", "audio/"+id)
 
 	var out bytes.Buffer
 	var stderr bytes.Buffer
@@ -128,7 +262,7 @@ func downloadAudio(url url.URL, id string) error {
 		return errors.New(fmt.Sprint(err) + ": " + stderr.String())
 	}
 
-	if start := url.Query().Get("t"); start != "" {
+	if start := url.Query().Get("t"); youTube && start != "" {
 		var seconds int
 		seconds, err = strconv.Atoi(start)
 		if err != nil {
@@ -138,7 +272,7 @@ func downloadAudio(url url.URL, id string) error {
 		parsedTime := time.Unix(0, (time.Duration(seconds) * time.Second).Nanoseconds())
 		timeString := strings.Split(parsedTime.String(), " ")[1]
 
-		cmdString = fmt.Sprintf(`ffmpeg -ss %s -i audio/%s.opus -c copy audio/%s_temp.opus -y`, timeString, id, id)
+		cmdString := fmt.Sprintf(`ffmpeg -ss %s -i audio/%s.opus -c copy audio/%s_temp.opus -y`, timeString, id, id)
 
 		cmd = exec.Command("sh", "-c", cmdString)
 
@@ -186,8 +320,48 @@ func parsePlaylist(u url.URL) ([]string, error) {
 	return videos, nil
 }
 
-func downloadAttachment(url string) (*Song, error) {
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+// maxAttachmentSize is the largest attachment that is downloaded.
+const maxAttachmentSize = 100 << 20
+
+// attachmentExts are the files accepted when Discord did not detect their
+// type, the library's audio files and common videos.
+var attachmentExts = map[string]bool{
+	".mp3": true, ".flac": true, ".ogg": true, ".opus": true, ".m4a": true, ".aac": true, ".wav": true,
+	".mp4": true, ".webm": true, ".mkv": true, ".mov": true,
+}
+
+// checkAttachment rejects attachments that are too large or not audio or
+// video, going by the file extension if Discord did not detect a type.
+func checkAttachment(a *discordgo.MessageAttachment) error {
+	if a.Size > maxAttachmentSize {
+		return fmt.Errorf("%s is %d MB, the limit is %d MB", a.Filename, a.Size>>20, maxAttachmentSize>>20)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(a.ContentType)
+	if strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/") {
+		return nil
+	}
+
+	undetected := mediaType == "" || mediaType == "application/octet-stream"
+	if undetected && attachmentExts[strings.ToLower(filepath.Ext(a.Filename))] {
+		return nil
+	}
+
+	if mediaType == "" {
+		mediaType = "unknown type"
+	}
+	return fmt.Errorf("%s is not an audio or video file (%s)", a.Filename, mediaType)
+}
+
+// downloadAttachment downloads and encodes an audio or video attachment,
+// named after its tags if it has any. The same file sent again is only
+// encoded once.
+func downloadAttachment(a *discordgo.MessageAttachment, e Encoding) (*Song, error) {
+	if err := checkAttachment(a); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, a.URL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -198,41 +372,76 @@ func downloadAttachment(url string) (*Song, error) {
 	}
 	defer res.Body.Close()
 
-	urlSegments := strings.Split(res.Request.URL.Path, "/")
-	fileName := strings.Split(urlSegments[len(urlSegments)-1], "?")[0]
-	title := strings.Split(fileName, ".")[0]
-	audioPath := fmt.Sprintf("audio/%s", fileName)
-	dcaPath := strings.Split(audioPath, ".")[0] + ".dca"
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading attachment: %s", res.Status)
+	}
 
-	file, err := os.Create(audioPath)
+	// Files are named after their content, so that sending one again reuses
+	// its cache.
+	tmp, err := os.CreateTemp("audio", "attachment-*.part")
 	if err != nil {
 		return nil, fmt.Errorf("error creating file: %w", err)
 	}
-	defer file.Close()
+	defer os.Remove(tmp.Name())
 
-	_, err = io.Copy(file, res.Body)
+	// The size Discord reports is checked already, this guards the download.
+	sum := sha1.New()
+	n, err := io.Copy(io.MultiWriter(tmp, sum), io.LimitReader(res.Body, maxAttachmentSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error copying file: %w", err)
 	}
+	if n > maxAttachmentSize {
+		return nil, fmt.Errorf("%s is over %d MB", a.Filename, maxAttachmentSize>>20)
+	}
 
-	cmdString := fmt.Sprintf("ffmpeg -i %s -f s16le -ar 48000 -ac 2 pipe:1 | ./dca > %s", audioPath, dcaPath)
-	cmd := exec.Command("sh", "-c", cmdString)
+	id := "attachment-" + hex.EncodeToString(sum.Sum(nil)[:8])
+	audioPath := "audio/" + id + strings.ToLower(filepath.Ext(a.Filename))
+	dcaPath := e.cachePath(id)
 
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
+	if err = os.Rename(tmp.Name(), audioPath); err != nil {
+		return nil, fmt.Errorf("error saving file: %w", err)
+	}
 
-	err = cmd.Run()
+	t, err := probeTrack(audioPath)
 	if err != nil {
-		return nil, errors.New(fmt.Sprint(err) + ": " + stderr.String())
+		removeAttachmentFiles(audioPath)
+		if errors.Is(err, errNoAudio) {
+			return nil, fmt.Errorf("%s has no audio", a.Filename)
+		}
+		return nil, fmt.Errorf("%s is not a supported audio or video format", a.Filename)
+	}
+	if t.Title == "" {
+		t.Title = strings.TrimSuffix(a.Filename, filepath.Ext(a.Filename))
 	}
 
-	if err = os.Remove(audioPath); err != nil {
-		return nil, fmt.Errorf("error removing audio file: %w", err)
+	if _, err = os.Stat(dcaPath); err != nil {
+		if err = encodeFile(audioPath, dcaPath, e); err != nil {
+			removeAttachmentFiles(audioPath)
+			return nil, err
+		}
 	}
 
-	song := NewSong(title, "", dcaPath)
+	song := NewSong(t.Name(), "", dcaPath, "", t.Duration)
+	song.sourcePath = audioPath
+	song.loudnessFile = loudnessPath(audioPath)
 
 	return song, nil
 }
+
+// attachmentBase strips the extension from an attachment's source, leaving
+// the path its cache files share.
+func attachmentBase(audioPath string) string {
+	return strings.TrimSuffix(audioPath, filepath.Ext(audioPath))
+}
+
+// removeAttachmentFiles deletes an attachment's source at audioPath along
+// with its cache files and stored loudness.
+func removeAttachmentFiles(audioPath string) {
+	paths, _ := filepath.Glob(attachmentBase(audioPath) + ".*")
+	for _, p := range paths {
+		_ = os.Remove(p)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const panelPrefix = "panel:"
+
+const (
+	panelPause    = panelPrefix + "pause"
+	panelSkip     = panelPrefix + "skip"
+	panelPrevious = panelPrefix + "previous"
+	panelLoop     = panelPrefix + "loop"
+	panelShuffle  = panelPrefix + "shuffle"
+	panelStop     = panelPrefix + "stop"
+)
+
+// panelCommands maps buttons to the commands whose permissions they follow.
+var panelCommands = map[string]string{
+	panelPause:    "pause",
+	panelSkip:     "skip",
+	panelPrevious: "previous",
+	panelShuffle:  "shuffle",
+	panelStop:     "clear",
+}
+
+// panelMessage renders the now playing embed and its control buttons.
+func (ch *CommandHandler) panelMessage(paused bool) ([]*discordgo.MessageEmbed, []discordgo.MessageComponent) {
+	ch.mu.RLock()
+	song := ch.current
+	loop := ch.loop
+	next := "Nothing"
+	if len(ch.queue) > 1 {
+		next = ch.queue[1].title
+	}
+	queued := len(ch.queue)
+	ch.mu.RUnlock()
+
+	title := "Nothing"
+	if song != nil {
+		title = song.title
+		if song.requester != "" {
+			title += fmt.Sprintf("\nRequested by <@%s>", song.requester)
+		}
+	}
+
+	status := "Now playing"
+	if song != nil && song.live {
+		status = "Now playing LIVE"
+	}
+	if paused {
+		status = "Paused"
+	}
+
+	loopStatus := "off"
+	loopStyle := discordgo.SecondaryButton
+	if loop {
+		loopStatus = "on"
+		loopStyle = discordgo.SuccessButton
+	}
+
+	pauseLabel, pauseEmoji := "Pause", "⏸️"
+	if paused {
+		pauseLabel, pauseEmoji = "Resume", "▶️"
+	}
+
+	embeds := []*discordgo.MessageEmbed{{
+		Title:       status,
+		Description: title,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Up next", Value: next},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Loop: %s | %d in queue", loopStatus, queued),
+		},
+	}}
+
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label: "Previous", Style: discordgo.SecondaryButton,
+				Emoji: &discordgo.ComponentEmoji{Name: "⏮️"}, CustomID: panelPrevious,
+			},
+			discordgo.Button{
+				Label: pauseLabel, Style: discordgo.PrimaryButton,
+				Emoji: &discordgo.ComponentEmoji{Name: pauseEmoji}, CustomID: panelPause,
+			},
+			discordgo.Button{
+				Label: "Skip", Style: discordgo.SecondaryButton,
+				Emoji: &discordgo.ComponentEmoji{Name: "⏭️"}, CustomID: panelSkip,
+			},
+			discordgo.Button{
+				Label: "Stop", Style: discordgo.DangerButton,
+				Emoji: &discordgo.ComponentEmoji{Name: "⏹️"}, CustomID: panelStop,
+			},
+		}},
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label: "Loop", Style: loopStyle,
+				Emoji: &discordgo.ComponentEmoji{Name: "🔁"}, CustomID: panelLoop,
+			},
+			discordgo.Button{
+				Label: "Shuffle", Style: discordgo.SecondaryButton,
+				Emoji: &discordgo.ComponentEmoji{Name: "🔀"}, CustomID: panelShuffle,
+			},
+		}},
+	}
+
+	return embeds, components
+}
+
+// ShowPanel posts the now playing panel in the channel playback was started
+// from, or refreshes it if it is already there.
+func (ch *CommandHandler) ShowPanel(paused bool) {
+	const op string = "ShowPanel: "
+
+	ch.panelMu.Lock()
+	defer ch.panelMu.Unlock()
+
+	if ch.session == nil || ch.textChan == "" {
+		return
+	}
+
+	embeds, components := ch.panelMessage(paused)
+
+	if ch.panel != nil {
+		_, err := ch.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:         ch.panel.ID,
+			Channel:    ch.panel.ChannelID,
+			Embeds:     &embeds,
+			Components: &components,
+		})
+		if err == nil {
+			return
+		}
+
+		// The panel was most likely deleted by someone, post a new one.
+		ch.lg.Error(op+"Error editing panel: ", err)
+		ch.panel = nil
+	}
+
+	msg, err := ch.session.ChannelMessageSendComplex(ch.textChan, &discordgo.MessageSend{
+		Embeds:     embeds,
+		Components: components,
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error sending panel: ", err)
+		return
+	}
+
+	ch.panel = msg
+}
+
+// RemovePanel deletes the now playing panel once playback has ended.
+func (ch *CommandHandler) RemovePanel() {
+	ch.panelMu.Lock()
+	defer ch.panelMu.Unlock()
+
+	if ch.panel == nil {
+		return
+	}
+
+	err := ch.session.ChannelMessageDelete(ch.panel.ChannelID, ch.panel.ID)
+	if err != nil {
+		ch.lg.Error("RemovePanel: Error deleting panel: ", err)
+	}
+
+	ch.panel = nil
+}
+
+func (ch *CommandHandler) handlePanel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handlePanel: "
+
+	if !ch.IsPlaying() {
+		ch.Success(s, i, "Nothing is playing")
+		return
+	}
+
+	paused := !ch.isSpeaking
+
+	customID := i.MessageComponentData().CustomID
+	if name, ok := panelCommands[customID]; ok && !ch.allowed(name, i) {
+		ch.Success(s, i, fmt.Sprintf("You need the DJ role to use /%s", name))
+		return
+	}
+
+	switch customID {
+	case panelPause:
+		if paused {
+			ch.ResumePlayback()
+		} else {
+			ch.PausePlayback()
+		}
+		paused = !paused
+	case panelLoop:
+		ch.ToggleLoop()
+	case panelShuffle:
+		ch.Shuffle()
+	case panelSkip:
+		if !ch.canManage(i.Member, ch.GetCurrentSong()) {
+			if !ch.settings.Get(GUILD).VoteSkip {
+				ch.Success(s, i, ErrNotOwner.Error())
+				return
+			}
+
+			msg, err := ch.voteSkipMessage(i)
+			if err != nil {
+				msg = err.Error()
+			}
+			ch.Success(s, i, msg)
+			return
+		}
+		ch.SkipSong()
+		ch.deferPanel(s, i)
+		return
+	case panelPrevious:
+		if _, err := ch.PlayPrevious(); err != nil {
+			ch.Success(s, i, err.Error())
+			return
+		}
+		ch.deferPanel(s, i)
+		return
+	case panelStop:
+		if err := ch.StopAs(i.Member); err != nil {
+			ch.Success(s, i, err.Error())
+			return
+		}
+		ch.deferPanel(s, i)
+		return
+	default:
+		ch.lg.Error(op + "Unknown button: " + customID)
+		return
+	}
+
+	embeds, components := ch.panelMessage(paused)
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     embeds,
+			Components: components,
+		},
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error updating panel: ", err)
+	}
+}
+
+// deferPanel acknowledges a button press whose effect updates the panel later.
+func (ch *CommandHandler) deferPanel(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		ch.lg.Error("deferPanel: Error responding to interaction: ", err)
+	}
+}
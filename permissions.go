@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const adminPermissions = discordgo.PermissionAdministrator | discordgo.PermissionManageGuild
+
+func isAdmin(member *discordgo.Member) bool {
+	return member.Permissions&adminPermissions != 0
+}
+
+// isDJ reports whether member has the guild's DJ role or is an admin. Without
+// a configured DJ role only admins count.
+func (ch *CommandHandler) isDJ(member *discordgo.Member) bool {
+	if isAdmin(member) {
+		return true
+	}
+
+	role := ch.settings.Get(GUILD).DJRole
+	return role != "" && slices.Contains(member.Roles, role)
+}
+
+// canManage reports whether member may remove or skip song.
+func (ch *CommandHandler) canManage(member *discordgo.Member, song *Song) bool {
+	if song == nil {
+		return true
+	}
+	return song.requester == member.User.ID || ch.isDJ(member)
+}
+
+// allowed reports whether the interaction's member may use the command name
+// under the guild's permission policy.
+func (ch *CommandHandler) allowed(name string, i *discordgo.InteractionCreate) bool {
+	settings := ch.settings.Get(GUILD)
+
+	if !slices.Contains(settings.DJCommands, name) || ch.isDJ(i.Member) {
+		return true
+	}
+
+	if settings.AloneExempt && ch.aloneWithBot(GUILD, i.Member.User.ID) {
+		return true
+	}
+
+	// Skipping your own song is always fine, see canManage, and with vote
+	// skipping anyone may vote.
+	if name == "skip" {
+		if settings.VoteSkip {
+			return true
+		}
+
+		song := ch.GetCurrentSong()
+		return song != nil && song.requester == i.Member.User.ID
+	}
+
+	return false
+}
+
+// requireDJ wraps a command handler with the guild's permission policy.
+func (ch *CommandHandler) requireDJ(name string, h HandlerFunc) HandlerFunc {
+	return func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		if !ch.allowed(name, i) {
+			ch.lg.Info("Denied /%s to %s", name, i.Member.User.ID)
+			ch.Success(s, i, fmt.Sprintf("You need the DJ role to use /%s", name))
+			return
+		}
+
+		h(s, i)
+	}
+}
+
+// commandsFor returns the commands to register in a guild with settings.
+func commandsFor(settings Settings) []*discordgo.ApplicationCommand {
+	// Discord requires every bit, Manage Server alone keeps them usable for
+	// members without Administrator.
+	perms := manageGuild
+	commands := make([]*discordgo.ApplicationCommand, 0, len(Commands))
+
+	for _, c := range Commands {
+		if settings.DiscordPermissions && slices.Contains(settings.DJCommands, c.Name) {
+			restricted := *c
+			restricted.DefaultMemberPermissions = &perms
+			c = &restricted
+		}
+		commands = append(commands, c)
+	}
+
+	return commands
+}
+
+func (ch *CommandHandler) RegisterCommands() error {
+	_, err := ch.session.ApplicationCommandBulkOverwrite(APP, GUILD, commandsFor(ch.settings.Get(GUILD)))
+	if err != nil {
+		return fmt.Errorf("error registering commands: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// prepareAhead is how long before the end of a song the next one starts
+	// loading.
+	prepareAhead = 2 * time.Second
+	maxCrossfade = 12
+)
+
+// player is the state PlaySong carries from one song to the next.
+type player struct {
+	vc       *discordgo.VoiceConnection
+	speaking bool
+	src      frameSource
+	// next is the song that plays once the current one is done. If it was
+	// started ahead of time, nextSrc plays it, or src once a crossfade into
+	// it began.
+	next    *Song
+	nextSrc frameSource
+	// noFade stops retrying a crossfade that failed to start.
+	noFade bool
+}
+
+func (p *player) closeNext() {
+	if p.nextSrc != nil {
+		_ = p.nextSrc.Close()
+		p.nextSrc = nil
+	}
+	p.next = nil
+}
+
+func (p *player) close() {
+	if p.src != nil {
+		_ = p.src.Close()
+		p.src = nil
+	}
+	p.closeNext()
+}
+
+// claimSong makes the song at the head of the queue the current one, loaded
+// and ready to play. It returns nil if there is nothing to play.
+func (ch *CommandHandler) claimSong() (*Song, *discordgo.VoiceConnection) {
+	for {
+		ch.mu.Lock()
+		if len(ch.queue) == 0 || ch.voiceConn == nil {
+			ch.mu.Unlock()
+			return nil, nil
+		}
+
+		song := ch.queue[0]
+		vc := ch.voiceConn
+		ch.current = song
+		ch.votes = make(map[string]bool)
+
+		// Drop a skip that arrived after the previous song had already ended,
+		// and a restart for settings changed while idle, which the new source
+		// picks up anyway.
+		select {
+		case <-ch.skipChan:
+		default:
+		}
+		select {
+		case <-ch.restartChan:
+		default:
+		}
+		ch.mu.Unlock()
+
+		ch.disarmIdle()
+
+		if err := song.LoadSound(); err != nil {
+			ch.lg.Error("Error loading audio file: %w", err)
+			ch.finishSong(song, false)
+			ch.removeSongPtr(song)
+			continue
+		}
+
+		return song, vc
+	}
+}
+
+// stopPlayer winds down once the queue ran out. It reports false if songs
+// were added in the meantime and the player should go on.
+func (ch *CommandHandler) stopPlayer(p *player) bool {
+	p.close()
+
+	if p.speaking {
+		if err := p.vc.Speaking(false); err != nil {
+			ch.lg.Error("Error setting voice to speaking: %w", err)
+		}
+		p.speaking = false
+	}
+
+	ch.isSpeaking = false
+	ch.RemovePanel()
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if len(ch.queue) > 0 && ch.voiceConn != nil {
+		return false
+	}
+
+	ch.dropPause()
+	ch.running = false
+	return true
+}
+
+func (ch *CommandHandler) stopRunning() {
+	ch.mu.Lock()
+	ch.dropPause()
+	ch.running = false
+	ch.mu.Unlock()
+}
+
+// dropPause forgets a pause or resume the stopped player never picked up, so
+// that it does not hit the next song.
+func (ch *CommandHandler) dropPause() {
+	select {
+	case <-ch.pauseChan:
+	default:
+	}
+}
+
+// playFrames sends song to voice until it ends, is skipped or already
+// crossfades into the next song. It reports false if the voice connection
+// was lost.
+func (ch *CommandHandler) playFrames(song *Song, p *player) bool {
+	switch {
+	case p.next == song && p.nextSrc != nil:
+		if p.src != nil {
+			_ = p.src.Close()
+		}
+		p.src, p.nextSrc = p.nextSrc, nil
+	case p.next != song || p.src == nil:
+		p.close()
+		p.src = ch.startSource(song)
+	}
+	p.closeNext()
+	p.noFade = false
+
+	skipped := false
+	ended := false
+	stalls := 0
+	retries := 0
+	var frame []byte
+	var err error
+
+loop:
+	for {
+		select {
+		case <-ch.skipChan:
+			skipped = true
+			break loop
+		case pause := <-ch.pauseChan:
+			if !pause {
+				continue
+			}
+			ch.isSpeaking = false
+		paused:
+			for {
+				select {
+				case pause = <-ch.pauseChan:
+					if !pause {
+						ch.isSpeaking = true
+						break paused
+					}
+				case <-ch.skipChan:
+					skipped = true
+					break loop
+				}
+			}
+		case <-ch.restartChan:
+			// Anything started ahead used the old settings.
+			p.closeNext()
+			_ = p.src.Close()
+			p.src = ch.startSource(song)
+			frame = nil
+		default:
+			if frame == nil {
+				if ch.handOver(song, p) {
+					ended = true
+					break loop
+				}
+
+				frame, err = p.src.Next()
+				if err != nil && song.live && song.duration == 0 {
+					// Streams only end when skipped, anything with a length
+					// ends on its own.
+					reopened, reopenErr := ch.reopenStream(song, p, retries)
+					if errors.Is(reopenErr, errSkipped) {
+						skipped = true
+						break loop
+					}
+					if reopened {
+						retries++
+						frame = nil
+						continue
+					}
+				}
+				if errors.Is(err, io.EOF) {
+					ended = true
+					break loop
+				}
+				if err != nil {
+					ch.lg.Error("Error reading audio: ", err)
+					break loop
+				}
+			}
+
+			err = sendFrame(p.vc, frame)
+			if errors.Is(err, errVoiceStalled) && stalls < maxStalls {
+				stalls++
+				continue
+			}
+			if err != nil {
+				stalls = 0
+				p.vc, err = ch.recoverVoice(p.vc, err)
+				if err != nil {
+					ch.saveBookmark(song, false)
+					ch.lostVoice(song, err)
+					return false
+				}
+				continue
+			}
+			stalls = 0
+			retries = 0
+			frame = nil
+			song.offset += p.src.Step()
+		}
+	}
+
+	ch.saveBookmark(song, ended)
+	song.offset = 0
+
+	ch.finishSong(song, skipped || !ch.IsLooping())
+
+	return true
+}
+
+// handOver gets the next song ready before song ends. With crossfade on, it
+// switches p.src to a mix into the next song and reports true, as song is
+// done then.
+func (ch *CommandHandler) handOver(song *Song, p *player) bool {
+	fade := time.Duration(ch.settings.Get(GUILD).CrossfadeSeconds) * time.Second
+	remaining := song.duration - song.offset
+
+	if song.duration == 0 || remaining > max(fade, prepareAhead) {
+		return false
+	}
+
+	next := ch.peekNext(song)
+	if next == nil {
+		return false
+	}
+
+	if fade > 0 && remaining <= fade && !p.noFade {
+		src, err := ch.startCrossfade(song, next, remaining)
+		if err == nil {
+			p.closeNext()
+			_ = p.src.Close()
+			p.src = src
+			p.next = next
+			return true
+		}
+
+		ch.lg.Error("handOver: Error starting crossfade: ", err)
+		p.noFade = true
+	}
+
+	if p.next == nil && remaining <= prepareAhead {
+		// Loading waits in LoadSound once the song is claimed.
+		go func() {
+			if err := next.LoadSound(); err != nil {
+				ch.lg.Error("handOver: Error loading next song: ", err)
+			}
+		}()
+
+		p.next = next
+		p.nextSrc = ch.startSource(next)
+	}
+
+	return false
+}
+
+// peekNext returns the song that plays after song, if it is another one.
+func (ch *CommandHandler) peekNext(song *Song) *Song {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+
+	if ch.loop || len(ch.queue) == 0 || ch.queue[0] != song {
+		return nil
+	}
+	if len(ch.queue) > 1 {
+		return ch.queue[1]
+	}
+	return nil
+}
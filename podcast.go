@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	podcastPrefix = "podcast:"
+	maxEpisodes   = 10
+	feedTimeout   = 15 * time.Second
+	maxFeedSize   = 10 << 20
+)
+
+var feedClient = &http.Client{Timeout: feedTimeout}
+
+// Episode is a podcast episode with an audio enclosure.
+type Episode struct {
+	Title     string
+	GUID      string
+	URL       string
+	Published time.Time
+	Duration  time.Duration
+}
+
+// Key identifies the episode in bookmarks, its GUID if the feed has one.
+func (e Episode) Key() string {
+	if e.GUID != "" {
+		return e.GUID
+	}
+	return e.URL
+}
+
+// Feed is a podcast with its episodes, most recent first.
+type Feed struct {
+	Title    string
+	Episodes []Episode
+}
+
+// feedXML holds both RSS and Atom feeds, told apart by the root element.
+type feedXML struct {
+	XMLName xml.Name
+	Title   string `xml:"title"`
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title   string `xml:"title"`
+	GUID    string `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	// Duration is itunes:duration.
+	Duration  string `xml:"duration"`
+	Enclosure struct {
+		URL string `xml:"url,attr"`
+	} `xml:"enclosure"`
+}
+
+type atomEntry struct {
+	Title     string `xml:"title"`
+	ID        string `xml:"id"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	Duration  string `xml:"duration"`
+	Links     []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+}
+
+// FetchFeed downloads and parses the RSS or Atom feed at feedURL.
+func FetchFeed(client *http.Client, feedURL string) (*Feed, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching feed: %s", res.Status)
+	}
+
+	return parseFeed(io.LimitReader(res.Body, maxFeedSize))
+}
+
+func parseFeed(r io.Reader) (*Feed, error) {
+	var raw feedXML
+	if err := xml.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error parsing feed: %w", err)
+	}
+
+	feed := &Feed{Episodes: make([]Episode, 0)}
+
+	switch raw.XMLName.Local {
+	case "rss":
+		feed.Title = strings.TrimSpace(raw.Channel.Title)
+		for _, item := range raw.Channel.Items {
+			feed.add(Episode{
+				Title:     item.Title,
+				GUID:      item.GUID,
+				URL:       item.Enclosure.URL,
+				Published: parseFeedTime(item.PubDate),
+				Duration:  parseClock(item.Duration),
+			})
+		}
+	case "feed":
+		feed.Title = strings.TrimSpace(raw.Title)
+		for _, entry := range raw.Entries {
+			ep := Episode{
+				Title:     entry.Title,
+				GUID:      entry.ID,
+				Published: parseFeedTime(entry.Published),
+				Duration:  parseClock(entry.Duration),
+			}
+			if ep.Published.IsZero() {
+				ep.Published = parseFeedTime(entry.Updated)
+			}
+			for _, link := range entry.Links {
+				if link.Rel == "enclosure" {
+					ep.URL = link.Href
+					break
+				}
+			}
+			feed.add(ep)
+		}
+	default:
+		return nil, fmt.Errorf("not an RSS or Atom feed: <%s>", raw.XMLName.Local)
+	}
+
+	// Episodes without a date keep their place at the end.
+	sort.SliceStable(feed.Episodes, func(a, b int) bool {
+		return feed.Episodes[a].Published.After(feed.Episodes[b].Published)
+	})
+
+	return feed, nil
+}
+
+// add keeps the episode if it has audio to play.
+func (f *Feed) add(ep Episode) {
+	ep.URL = strings.TrimSpace(ep.URL)
+	if ep.URL == "" {
+		return
+	}
+
+	ep.Title = strings.TrimSpace(ep.Title)
+	ep.GUID = strings.TrimSpace(ep.GUID)
+	if ep.Title == "" {
+		ep.Title = path.Base(ep.URL)
+	}
+
+	f.Episodes = append(f.Episodes, ep)
+}
+
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+}
+
+// parseFeedTime parses the dates found in feeds, zero if none match.
+func parseFeedTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseClock parses durations like 1:02:03, 62:03 or 3723, zero if invalid.
+func parseClock(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+
+	var seconds int
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// episodeID names the cache files of the episode.
+func episodeID(ep Episode) string {
+	sum := sha1.Sum([]byte(ep.URL))
+	return "podcast-" + hex.EncodeToString(sum[:8])
+}
+
+// AddEpisode downloads a podcast episode like any other song and queues it to
+// continue where it was last stopped in guildID.
+func (ch *CommandHandler) AddEpisode(ep Episode, guildID, requester string) (string, error) {
+	u, err := url.Parse(ep.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid episode URL: %s", ep.URL)
+	}
+
+	if err = ch.checkContent(SearchResult{Title: ep.Title, Duration: ep.Duration}); err != nil {
+		return "", err
+	}
+
+	if err = ch.checkQueueLimits(requester, ep.Duration); err != nil {
+		return "", err
+	}
+
+	id := episodeID(ep)
+	enc := ch.encoding()
+	audioPath := enc.cachePath(id)
+
+	if _, err = os.Stat(audioPath); err != nil {
+		audioPath, err = DownloadSong(*u, id, enc)
+		if err != nil {
+			return "", fmt.Errorf("failed download the episode: %w", err)
+		}
+	}
+
+	duration := ep.Duration
+	if duration == 0 {
+		// Not every feed has durations, the limits are checked once known.
+		duration, err = probeDuration(sourcePath(id))
+		if err != nil {
+			return "", fmt.Errorf("error getting duration: %w", err)
+		}
+		if err = ch.checkContent(SearchResult{Title: ep.Title, Duration: duration}); err != nil {
+			return "", err
+		}
+	}
+
+	song := NewSong(ep.Title, id, audioPath, requester, duration)
+	song.sourcePath = sourcePath(id)
+	song.mediaURL = ep.URL
+	song.episode = ep.Key()
+	song.guildID = guildID
+	song.loudnessFile = loudnessPath(song.sourcePath)
+
+	if pos := ch.bookmarks.Get(guildID, song.episode); pos < duration {
+		song.offset = pos
+	}
+
+	if err = ch.EnqueueSong(song); err != nil {
+		return "", err
+	}
+
+	return song.title, nil
+}
+
+func (ch *CommandHandler) handlePodcast(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handlePodcast: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+
+	switch sub.Name {
+	case "add":
+		ch.podcastAdd(s, i, strings.TrimSpace(sub.Options[0].StringValue()))
+	default:
+		ch.lg.Error(op + "Unknown subcommand: " + sub.Name)
+		ch.Error(s, i, errors.New("unknown subcommand: "+sub.Name))
+	}
+}
+
+// podcastAdd lists the recent episodes of the feed at feedURL to pick from.
+func (ch *CommandHandler) podcastAdd(s *discordgo.Session, i *discordgo.InteractionCreate, feedURL string) {
+	const op string = "podcastAdd: "
+
+	if !IsURL(feedURL) {
+		ch.Error(s, i, fmt.Errorf("not a feed URL: %s", feedURL))
+		return
+	}
+
+	feed, err := FetchFeed(feedClient, feedURL)
+	if err != nil {
+		ch.lg.Error(op+"Error fetching feed: ", err)
+		ch.Error(s, i, fmt.Errorf("Error fetching feed: %w", err))
+		return
+	}
+
+	if len(feed.Episodes) == 0 {
+		ch.Error(s, i, fmt.Errorf("no episodes with audio in: %s", feedURL))
+		return
+	}
+
+	episodes := feed.Episodes[:min(len(feed.Episodes), maxEpisodes)]
+
+	options := make([]discordgo.SelectMenuOption, 0, len(episodes))
+	b := strings.Builder{}
+	b.WriteString(feed.Title + "\n")
+
+	for n, ep := range episodes {
+		details := make([]string, 0, 3)
+		if !ep.Published.IsZero() {
+			details = append(details, ep.Published.Format("2006-01-02"))
+		}
+		if ep.Duration > 0 {
+			details = append(details, FormatDuration(ep.Duration))
+		}
+		if pos := ch.bookmarks.Get(i.GuildID, ep.Key()); pos > 0 {
+			details = append(details, "resumes at "+FormatDuration(pos))
+		}
+
+		b.WriteString(fmt.Sprintf("%d. %s (%s)\n", n+1, ep.Title, strings.Join(details, ", ")))
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       truncate(fmt.Sprintf("%d. %s", n+1, ep.Title), 100),
+			Value:       strconv.Itoa(n),
+			Description: truncate(strings.Join(details, " | "), 100),
+		})
+	}
+
+	minValues := 1
+	menu := discordgo.SelectMenu{
+		Placeholder: "Pick episodes to add",
+		MinValues:   &minValues,
+		MaxValues:   len(options),
+		Options:     options,
+	}
+
+	err = ch.showPicker(s, i, podcastPrefix, b.String(), menu, func(s *discordgo.Session, ci *discordgo.InteractionCreate) {
+		ch.handlePodcastPick(s, ci, episodes)
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error sending episodes: ", err)
+	}
+}
+
+func (ch *CommandHandler) handlePodcastPick(s *discordgo.Session, i *discordgo.InteractionCreate, episodes []Episode) {
+	const op string = "handlePodcastPick: "
+
+	picked := make([]Episode, 0)
+	for _, v := range i.MessageComponentData().Values {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n >= len(episodes) {
+			ch.lg.Error(op + "Unknown episode picked: " + v)
+			continue
+		}
+		picked = append(picked, episodes[n])
+	}
+
+	if len(picked) == 0 {
+		ch.Success(s, i, "Unknown episode")
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("Adding %d episodes", len(picked)),
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error responding to interaction: ", err)
+	}
+
+	skipped := &SkippedError{}
+	for _, ep := range picked {
+		title, err := ch.AddEpisode(ep, i.GuildID, i.Member.User.ID)
+		if err != nil {
+			ch.lg.Error(op+"Error adding episode: ", err)
+			skipped.Add(ep.Title, err)
+			continue
+		}
+		ch.lg.Info("Successfully added: %s", title)
+	}
+
+	skipped.Added = len(picked) - len(skipped.Reasons)
+
+	switch {
+	case skipped.Added == 0:
+		ch.Error(s, i, fmt.Errorf("no episodes could be added: %w", skipped))
+		return
+	case len(skipped.Reasons) > 0:
+		ch.WaitSuccess(s, i, "Added to queue, "+skipped.Error())
+	default:
+		ch.WaitSuccess(s, i, fmt.Sprintf("Added to queue: %d episodes", skipped.Added))
+	}
+
+	ch.startPlayback(s, i)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title> Test Cast </title>
+    <item>
+      <title>Older</title>
+      <guid>ep-1</guid>
+      <pubDate>Mon, 02 Jan 2023 15:04:05 +0000</pubDate>
+      <itunes:duration>1:02:03</itunes:duration>
+      <enclosure url="https://example.com/1.mp3" type="audio/mpeg" length="1"/>
+    </item>
+    <item>
+      <title>No audio</title>
+      <guid>ep-2</guid>
+      <pubDate>Tue, 03 Jan 2023 15:04:05 +0000</pubDate>
+    </item>
+    <item>
+      <title>Newer</title>
+      <pubDate>Wed, 04 Jan 2023 15:04:05 GMT</pubDate>
+      <itunes:duration>95</itunes:duration>
+      <enclosure url=" https://example.com/3.mp3 " type="audio/mpeg" length="1"/>
+    </item>
+  </channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom Cast</title>
+  <entry>
+    <title>First</title>
+    <id>urn:ep:1</id>
+    <updated>2023-01-02T15:04:05Z</updated>
+    <link rel="alternate" href="https://example.com/1.html"/>
+    <link rel="enclosure" href="https://example.com/1.ogg"/>
+  </entry>
+  <entry>
+    <title>Second</title>
+    <id>urn:ep:2</id>
+    <published>2023-01-05T15:04:05Z</published>
+    <link rel="alternate" href="https://example.com/2.html"/>
+  </entry>
+  <entry>
+    <id>urn:ep:3</id>
+    <published>2023-01-03T15:04:05Z</published>
+    <link rel="enclosure" href="https://example.com/files/third.ogg"/>
+  </entry>
+</feed>`
+
+func serveFeed(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestFetchFeedRSS(t *testing.T) {
+	srv := serveFeed(t, http.StatusOK, rssFeed)
+
+	feed, err := FetchFeed(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("FetchFeed: %v", err)
+	}
+
+	if feed.Title != "Test Cast" {
+		t.Errorf("title = %q, want %q", feed.Title, "Test Cast")
+	}
+
+	// The episode without an enclosure is dropped, the rest newest first.
+	want := []Episode{
+		{
+			Title:     "Newer",
+			URL:       "https://example.com/3.mp3",
+			Published: time.Date(2023, 1, 4, 15, 4, 5, 0, time.UTC),
+			Duration:  95 * time.Second,
+		},
+		{
+			Title:     "Older",
+			GUID:      "ep-1",
+			URL:       "https://example.com/1.mp3",
+			Published: time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC),
+			Duration:  time.Hour + 2*time.Minute + 3*time.Second,
+		},
+	}
+	checkEpisodes(t, feed.Episodes, want)
+
+	if key := feed.Episodes[0].Key(); key != "https://example.com/3.mp3" {
+		t.Errorf("key without a GUID = %q, want the URL", key)
+	}
+}
+
+func TestFetchFeedAtom(t *testing.T) {
+	srv := serveFeed(t, http.StatusOK, atomFeed)
+
+	feed, err := FetchFeed(srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("FetchFeed: %v", err)
+	}
+
+	if feed.Title != "Atom Cast" {
+		t.Errorf("title = %q, want %q", feed.Title, "Atom Cast")
+	}
+
+	// Only enclosure links count, and an untitled entry is named after its
+	// file.
+	want := []Episode{
+		{
+			Title:     "third.ogg",
+			GUID:      "urn:ep:3",
+			URL:       "https://example.com/files/third.ogg",
+			Published: time.Date(2023, 1, 3, 15, 4, 5, 0, time.UTC),
+		},
+		{
+			Title:     "First",
+			GUID:      "urn:ep:1",
+			URL:       "https://example.com/1.ogg",
+			Published: time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC),
+		},
+	}
+	checkEpisodes(t, feed.Episodes, want)
+}
+
+func TestFetchFeedStatus(t *testing.T) {
+	srv := serveFeed(t, http.StatusNotFound, "not found")
+
+	_, err := FetchFeed(srv.Client(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("FetchFeed error = %v, want the 404 status", err)
+	}
+}
+
+func TestParseFeedInvalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"html":   "<html><body>not a feed</body></html>",
+		"broken": "<rss><channel>",
+	} {
+		if _, err := parseFeed(strings.NewReader(body)); err == nil {
+			t.Errorf("%s: parseFeed returned no error", name)
+		}
+	}
+}
+
+func TestParseFeedNoEnclosures(t *testing.T) {
+	feed, err := parseFeed(strings.NewReader(`<rss><channel><title>Empty</title>
+		<item><title>Text only</title></item></channel></rss>`))
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+
+	if len(feed.Episodes) != 0 {
+		t.Errorf("got %d episodes, want none", len(feed.Episodes))
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"":          0,
+		"3723":      3723 * time.Second,
+		"62:03":     62*time.Minute + 3*time.Second,
+		"1:02:03":   time.Hour + 2*time.Minute + 3*time.Second,
+		" 0:45 ":    45 * time.Second,
+		"1:xx":      0,
+		"-5":        0,
+		"1.5":       0,
+		"01:00:00":  time.Hour,
+		"100:00:00": 100 * time.Hour,
+	} {
+		if got := parseClock(in); got != want {
+			t.Errorf("parseClock(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestParseFeedTime(t *testing.T) {
+	want := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	for _, in := range []string{
+		"Mon, 02 Jan 2023 15:04:05 +0000",
+		"Mon, 2 Jan 2023 15:04:05 +0000",
+		"Mon, 02 Jan 2023 16:04:05 +0100",
+		"2023-01-02T15:04:05Z",
+		"2 Jan 2023 15:04:05 +0000",
+		" 2023-01-02T15:04:05Z ",
+	} {
+		if got := parseFeedTime(in); !got.Equal(want) {
+			t.Errorf("parseFeedTime(%q) = %v, want %v", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "yesterday", "2023-01-02"} {
+		if got := parseFeedTime(in); !got.IsZero() {
+			t.Errorf("parseFeedTime(%q) = %v, want zero", in, got)
+		}
+	}
+}
+
+func checkEpisodes(t *testing.T, got, want []Episode) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d episodes, want %d: %+v", len(got), len(want), got)
+	}
+
+	for n := range want {
+		g, w := got[n], want[n]
+		if g.Title != w.Title || g.GUID != w.GUID || g.URL != w.URL ||
+			!g.Published.Equal(w.Published) || g.Duration != w.Duration {
+			t.Errorf("episode %d = %+v, want %+v", n, g, w)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -15,71 +16,361 @@ import (
 )
 
 type CommandHandler struct {
-	mu         sync.RWMutex
-	queue      []*Song
-	lg         *logger
-	voiceConn  *discordgo.VoiceConnection
-	inVC       bool
-	isSpeaking bool
-	skipChan   chan struct{}
-	pauseChan  chan struct{}
-	ctx        context.Context
-}
-
-func NewCommandHandler(logger *logger) *CommandHandler {
+	mu          sync.RWMutex
+	queue       []*Song
+	history     []HistoryEntry
+	current     *Song
+	loop        bool
+	requeue     bool
+	autoPaused  bool
+	idleTimer   *time.Timer
+	votes       map[string]bool
+	lg          *logger
+	session     *discordgo.Session
+	router      *Router
+	suggest     *suggestions
+	settings    *SettingsStore
+	bookmarks   *BookmarkStore
+	library     *Library
+	voiceConn   *discordgo.VoiceConnection
+	voiceChan   string
+	textChan    string
+	panel       *discordgo.Message
+	panelMu     sync.Mutex
+	inVC        bool
+	isSpeaking  bool
+	running     bool
+	skipChan    chan struct{}
+	restartChan chan struct{}
+	filter      Filter
+	pauseChan   chan bool
+	ctx         context.Context
+}
+
+func NewCommandHandler(
+	logger *logger, session *discordgo.Session, router *Router, settings *SettingsStore,
+	bookmarks *BookmarkStore, library *Library,
+) *CommandHandler {
 	return &CommandHandler{
-		sync.RWMutex{},
-		make([]*Song, 0),
-		logger,
-		nil,
-		false,
-		false,
-		make(chan struct{}),
-		make(chan struct{}),
-		context.Background(),
+		queue:       make([]*Song, 0),
+		history:     make([]HistoryEntry, 0, maxHistory),
+		lg:          logger,
+		session:     session,
+		router:      router,
+		suggest:     newSuggestions(),
+		settings:    settings,
+		bookmarks:   bookmarks,
+		library:     library,
+		skipChan:    make(chan struct{}, 1),
+		restartChan: make(chan struct{}, 1),
+		pauseChan:   make(chan bool, 1),
+		ctx:         context.Background(),
+	}
+}
+
+func (ch *CommandHandler) AddSong(url url.URL, id, requester string) (string, error) {
+	song, err := ch.LoadSong(url, id, requester)
+	if err != nil {
+		return "", err
+	}
+
+	if err = ch.EnqueueSong(song); err != nil {
+		return "", err
 	}
+
+	return song.title, nil
 }
 
-func (ch *CommandHandler) AddSong(url url.URL, id string) (string, error) {
-	title, err := GetSongTitle(id)
+// LoadSong makes a song from a YouTube video, downloading it unless it is
+// already cached. Songs over the requester's limits are not downloaded.
+func (ch *CommandHandler) LoadSong(url url.URL, id, requester string) (*Song, error) {
+	info, err := GetSongInfo(id)
 	if err != nil {
-		return "", fmt.Errorf("failed to get song title: %w", err)
+		return nil, fmt.Errorf("failed to get song title: %w", err)
+	}
+
+	if err = ch.checkContent(info); err != nil {
+		return nil, err
+	}
+
+	if err = ch.checkQueueLimits(requester, info.Duration); err != nil {
+		return nil, err
+	}
+
+	// Live streams play straight from YouTube without being cached.
+	if info.Live {
+		song := NewStream(info.Title, WatchURL(id).String(), requester)
+		song.id = id
+		return song, nil
 	}
 
-	audioPath := "audio/" + id + ".dca"
+	enc := ch.encoding()
+	audioPath := enc.cachePath(id)
 
 	_, err = os.Stat(audioPath)
 	if err != nil {
-		audioPath, err = DownloadSong(url, id)
+		audioPath, err = DownloadSong(url, id, enc)
 		if err != nil {
-			return "", fmt.Errorf("failed download the song: %w", err)
+			return nil, fmt.Errorf("failed download the song: %w", err)
 		}
 	}
 
-	song := NewSong(title, id, audioPath)
-	ch.AppendSong(song)
+	song := NewSong(info.Title, id, audioPath, requester, info.Duration)
+	song.sourcePath = sourcePath(id)
+	song.loudnessFile = loudnessPath(song.sourcePath)
 
-	return title, nil
+	return song, nil
 }
 
 func (ch *CommandHandler) RemoveSong(index int) (string, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
 	if index <= 0 || index > len(ch.queue) {
 		return "", fmt.Errorf("index out of range: %d", index)
 	}
 
 	title := ch.queue[index-1].title
+	ch.queue = append(ch.queue[:index-1], ch.queue[index:]...)
 
+	return title, nil
+}
+
+// RemoveSongAs removes the song at position index if member may manage it.
+func (ch *CommandHandler) RemoveSongAs(index int, member *discordgo.Member) (string, error) {
 	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if index <= 0 || index > len(ch.queue) {
+		return "", fmt.Errorf("index out of range: %d", index)
+	}
+
+	song := ch.queue[index-1]
+	if !ch.canManage(member, song) {
+		return "", ErrNotOwner
+	}
+
 	ch.queue = append(ch.queue[:index-1], ch.queue[index:]...)
+
+	return song.title, nil
+}
+
+// MoveSong moves the song at position from to position to. If this changes
+// the head of the queue while playing, the interrupted song is kept in the
+// queue and the new head starts playing.
+func (ch *CommandHandler) MoveSong(from, to int) (string, error) {
+	ch.mu.Lock()
+
+	if from <= 0 || from > len(ch.queue) {
+		ch.mu.Unlock()
+		return "", fmt.Errorf("index out of range: %d", from)
+	}
+
+	if to <= 0 || to > len(ch.queue) {
+		ch.mu.Unlock()
+		return "", fmt.Errorf("index out of range: %d", to)
+	}
+
+	song := ch.queue[from-1]
+	song.pinned = true
+	ch.queue = append(ch.queue[:from-1], ch.queue[from:]...)
+	ch.queue = append(ch.queue[:to-1], append([]*Song{song}, ch.queue[to-1:]...)...)
+
+	restart := ch.current != nil && ch.queue[0] != ch.current
+	if restart {
+		ch.requeue = true
+	}
 	ch.mu.Unlock()
 
+	if restart {
+		ch.SkipSong()
+	}
+
+	return song.title, nil
+}
+
+// SkipTo drops every song before position index and plays the song there.
+// member has to be allowed to manage every song that is dropped.
+func (ch *CommandHandler) SkipTo(index int, member *discordgo.Member) (string, error) {
+	ch.mu.Lock()
+
+	if index <= 0 || index > len(ch.queue) {
+		ch.mu.Unlock()
+		return "", fmt.Errorf("index out of range: %d", index)
+	}
+
+	if ch.current != nil && ch.queue[index-1] == ch.current {
+		ch.mu.Unlock()
+		return "", errors.New("song is already playing")
+	}
+
+	if err := ch.canDrop(ch.queue[:index-1], member); err != nil {
+		ch.mu.Unlock()
+		return "", err
+	}
+
+	song := ch.queue[index-1]
+	ch.queue = ch.queue[index-1:]
+	playing := ch.current != nil
+	ch.mu.Unlock()
+
+	if playing {
+		ch.SkipSong()
+	} else {
+		go ch.PlaySong()
+	}
+
+	return song.title, nil
+}
+
+// PlayNext puts the songs from a YouTube URL right after the current song.
+func (ch *CommandHandler) PlayNext(songURL, requester string) (string, error) {
+	u, err := url.Parse(songURL)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing URL: %w", err)
+	}
+
+	if !IsYouTubeURL(u) {
+		return "", fmt.Errorf("invalid YT link: %s", songURL)
+	}
+
+	ids, err := GetSongID(*u)
+	if err != nil {
+		return "", fmt.Errorf("Error getting song ID: %w", err)
+	}
+
+	if err = ch.checkPlaylistSize(len(ids)); err != nil {
+		return "", err
+	}
+
+	if len(ids) == 1 {
+		song, err := ch.LoadSong(*u, ids[0], requester)
+		if err != nil {
+			return "", err
+		}
+
+		if err = ch.PlaceSong(2, song, true); err != nil {
+			return "", err
+		}
+
+		return song.title, nil
+	}
+
+	// Songs are placed as they load so that every one counts towards the
+	// limits of the next. In fair mode a playlist takes turns like any other
+	// songs, or it would shut everyone else out.
+	pin := !ch.settings.Get(GUILD).FairQueue
+	added := 0
+	skipped := &SkippedError{}
+	for _, id := range ids {
+		song, err := ch.LoadSong(*u, id, requester)
+		if err == nil {
+			err = ch.PlaceSong(2+added, song, pin)
+		}
+		if err != nil {
+			ch.lg.Error("Error adding song: ", err)
+			skipped.Add(id, err)
+			continue
+		}
+		added++
+	}
+
+	if added == 0 {
+		return "", fmt.Errorf("no songs could be added: %w", skipped)
+	}
+
+	title := fmt.Sprintf("%d songs", added)
+	if len(skipped.Reasons) > 0 {
+		skipped.Added = added
+		return title, skipped
+	}
 	return title, nil
 }
 
+// PlaceSong puts song at position index unless that breaks the queue limits.
+// A pinned song keeps that position in fair mode, others are reordered.
+func (ch *CommandHandler) PlaceSong(index int, song *Song, pin bool) error {
+	ch.mu.Lock()
+	err := ch.queueLimits(song.requester, song.duration)
+	if err == nil {
+		song.pinned = pin
+		ch.insertSongs(index, song)
+		if !pin && ch.settings.Get(GUILD).FairQueue {
+			ch.fairReorder()
+		}
+	}
+	ch.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	ch.measureAhead(song)
+
+	return nil
+}
+
+// InsertSongs inserts songs so that the first one ends up at position index,
+// or at the end of the queue if it is shorter than that.
+func (ch *CommandHandler) InsertSongs(index int, songs ...*Song) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	ch.insertSongs(index, songs...)
+}
+
+// insertSongs is InsertSongs for callers that hold ch.mu.
+func (ch *CommandHandler) insertSongs(index int, songs ...*Song) {
+	index = min(max(index, 1), len(ch.queue)+1)
+
+	rest := append([]*Song{}, ch.queue[index-1:]...)
+	ch.queue = append(append(ch.queue[:index-1], songs...), rest...)
+}
+
+// removeSongPtr removes the given song from the queue wherever it currently is.
+func (ch *CommandHandler) removeSongPtr(song *Song) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	for i, s := range ch.queue {
+		if s == song {
+			ch.queue = append(ch.queue[:i], ch.queue[i+1:]...)
+			return
+		}
+	}
+}
+
 func (ch *CommandHandler) AppendSong(song *Song) {
 	ch.mu.Lock()
+	ch.appendSong(song)
+	ch.mu.Unlock()
+}
+
+// appendSong is AppendSong for callers that hold ch.mu.
+func (ch *CommandHandler) appendSong(song *Song) {
 	ch.queue = append(ch.queue, song)
+	if ch.settings.Get(GUILD).FairQueue {
+		ch.fairReorder()
+	}
+}
+
+// EnqueueSong appends song unless that breaks the queue limits. The check
+// and the append happen at once, so that concurrent adds cannot all pass.
+func (ch *CommandHandler) EnqueueSong(song *Song) error {
+	ch.mu.Lock()
+	err := ch.queueLimits(song.requester, song.duration)
+	if err == nil {
+		ch.appendSong(song)
+	}
 	ch.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	ch.measureAhead(song)
+
+	return nil
 }
 
 func (ch *CommandHandler) ClearQueue() {
@@ -89,7 +380,9 @@ func (ch *CommandHandler) ClearQueue() {
 }
 
 func (ch *CommandHandler) GetCurrentSong() *Song {
-	return ch.queue[0]
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.current
 }
 
 func (ch *CommandHandler) GetSongQueue() []*Song {
@@ -108,11 +401,17 @@ func (ch *CommandHandler) GetFormattedQueue() string {
 
 	b.WriteString("Currently playing:\n")
 	for i, song := range songs {
+		b.WriteString(fmt.Sprintf("%d. %s", i+1, song.title))
+		if song.live || song.duration > 0 {
+			b.WriteString(fmt.Sprintf(" [%s]", song.Length()))
+		}
+		if song.requester != "" {
+			b.WriteString(fmt.Sprintf(" (<@%s>)", song.requester))
+		}
 		if i == 0 {
-			b.WriteString(fmt.Sprintf("%d. %s <--\n", i+1, song.title))
-		} else {
-			b.WriteString(fmt.Sprintf("%d. %s\n", i+1, song.title))
+			b.WriteString(" <--")
 		}
+		b.WriteString("\n")
 	}
 
 	return b.String()
@@ -120,131 +419,316 @@ func (ch *CommandHandler) GetFormattedQueue() string {
 
 func (ch *CommandHandler) Shuffle() {
 	ch.mu.Lock()
-	for i := len(ch.queue) - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
+	// Keep the currently playing song at the head of the queue.
+	start := 0
+	if ch.current != nil {
+		start = 1
+	}
+	for i := len(ch.queue) - 1; i > start; i-- {
+		j := start + rand.Intn(i-start+1)
 		ch.queue[i], ch.queue[j] = ch.queue[j], ch.queue[i]
 	}
 	ch.mu.Unlock()
 }
 
+// ToggleLoop switches repeating of the current song and returns the new state.
+func (ch *CommandHandler) ToggleLoop() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.loop = !ch.loop
+	return ch.loop
+}
+
+func (ch *CommandHandler) IsLooping() bool {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.loop
+}
+
+func (ch *CommandHandler) IsPlaying() bool {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.current != nil
+}
+
 func (ch *CommandHandler) IsEmpty() bool {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 	return len(ch.queue) == 0
 }
 
+// PlaySong plays the queue until it runs out, unless the player is already
+// running. Songs follow each other without a gap, or crossfade if enabled.
 func (ch *CommandHandler) PlaySong() {
-	if ch.isSpeaking {
-		ch.lg.Error("Already playing")
-		return
-	}
-
-	if ch.IsEmpty() {
-		ch.lg.Error("no songs in queue")
+	ch.mu.Lock()
+	if ch.running {
+		// The running player picks up whatever was added.
+		ch.mu.Unlock()
 		return
 	}
+	ch.running = true
+	ch.mu.Unlock()
 
-	song := ch.GetCurrentSong()
+	p := &player{}
 
-	err := song.LoadSound()
-	if err != nil {
-		ch.lg.Error("Error loading audio file: %w", err)
-		return
-	}
+	for {
+		song, vc := ch.claimSong()
+		if song == nil {
+			if ch.stopPlayer(p) {
+				ch.armIdle()
+				return
+			}
+			continue
+		}
 
-	err = ch.voiceConn.Speaking(true)
-	if err != nil {
-		ch.lg.Error("Error starting speaking: %w", err)
-		return
-	}
+		if vc != p.vc {
+			p.vc = vc
+			p.speaking = false
+		}
 
-	ch.isSpeaking = true
-
-	ch.lg.Info("Playing song: %s", song.title)
-
-loop:
-	for _, buff := range song.buffer {
-		select {
-		case <-ch.skipChan:
-			break loop
-		case <-ch.pauseChan:
-			ch.isSpeaking = false
-		inner:
-			select {
-			case <-ch.pauseChan:
-				ch.isSpeaking = true
-				break inner
-			case <-ch.skipChan:
-				break loop
-			}
-		default:
-			if ch.voiceConn != nil && ch.isSpeaking {
-				ch.voiceConn.OpusSend <- buff
+		if !p.speaking {
+			if err := p.vc.Speaking(true); err != nil {
+				ch.lg.Error("Error starting speaking: %w", err)
+				ch.finishSong(song, false)
+				p.close()
+				ch.stopRunning()
+				return
 			}
+			p.speaking = true
+		}
+
+		ch.isSpeaking = true
+
+		ch.lg.Info("Playing song: %s", song.title)
+
+		ch.ShowPanel(false)
+
+		if !ch.playFrames(song, p) {
+			p.close()
+			ch.stopRunning()
+			return
 		}
 	}
+}
 
-	err = ch.voiceConn.Speaking(false)
-	if err != nil {
-		ch.lg.Error("Error setting voice to speaking: %w", err)
+// finishSong releases the player after song stops. Unless the song was asked
+// to stay in the queue, done moves it from the queue into the history.
+func (ch *CommandHandler) finishSong(song *Song, done bool) {
+	ch.mu.Lock()
+	ch.current = nil
+	requeue := ch.requeue
+	ch.requeue = false
+	ch.mu.Unlock()
+
+	if !done || requeue {
 		return
 	}
 
-	ch.isSpeaking = false
+	ch.removeSongPtr(song)
+	ch.pushHistory(song)
+}
+
+// Stop clears the queue and stops the current song.
+func (ch *CommandHandler) Stop() {
+	ch.ClearQueue()
 
-	_, err = ch.RemoveSong(1)
-	if err != nil {
-		ch.lg.Error("Error removing song: %w", err)
-		return
+	if ch.IsPlaying() {
+		ch.SkipSong()
 	}
+}
 
-	time.Sleep(500 * time.Millisecond)
+// StopAs stops like Stop if member may manage every queued song.
+func (ch *CommandHandler) StopAs(member *discordgo.Member) error {
+	ch.mu.RLock()
+	err := ch.canDrop(ch.queue, member)
+	ch.mu.RUnlock()
 
-	if ch.IsEmpty() {
-		return
+	if err != nil {
+		return err
 	}
 
-	go ch.PlaySong()
+	ch.Stop()
+
+	return nil
+}
+
+// canDrop reports ErrNotOwner for the first of songs that member may not
+// manage. Callers hold ch.mu.
+func (ch *CommandHandler) canDrop(songs []*Song, member *discordgo.Member) error {
+	for _, song := range songs {
+		if !ch.canManage(member, song) {
+			return fmt.Errorf("%w: %s", ErrNotOwner, song.title)
+		}
+	}
+	return nil
 }
 
 func (ch *CommandHandler) PausePlayback() {
-	ch.pauseChan <- struct{}{}
+	ch.setPaused(true)
 }
 
 func (ch *CommandHandler) ResumePlayback() {
-	ch.pauseChan <- struct{}{}
+	ch.setPaused(false)
+}
+
+// setPaused asks the player to pause or resume without waiting for it. A
+// request it has not picked up yet is replaced, so only the latest counts.
+func (ch *CommandHandler) setPaused(paused bool) {
+	select {
+	case <-ch.pauseChan:
+	default:
+	}
+	select {
+	case ch.pauseChan <- paused:
+	default:
+	}
 }
 
 func (ch *CommandHandler) SkipSong() {
-	ch.skipChan <- struct{}{}
+	select {
+	case ch.skipChan <- struct{}{}:
+	default:
+	}
 }
 
-func (ch *CommandHandler) HandleFileAttachment(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if len(i.ApplicationCommandData().Options) == 0 {
-		return errors.New("no options provided")
+// HandleFileAttachment adds every attached file in order. If only some could
+// be added, the error is a SkippedError saying why the others were not.
+func (ch *CommandHandler) HandleFileAttachment(_ *discordgo.Session, i *discordgo.InteractionCreate) error {
+	data := i.ApplicationCommandData()
+
+	attachments := make([]*discordgo.MessageAttachment, 0)
+	for _, opt := range data.Options {
+		if opt.Type != discordgo.ApplicationCommandOptionAttachment {
+			continue
+		}
+		attachmentID, _ := opt.Value.(string)
+		if a, ok := data.Resolved.Attachments[attachmentID]; ok {
+			attachments = append(attachments, a)
+		}
+	}
+
+	if len(attachments) == 0 {
+		return errors.New("no files attached")
+	}
+
+	skipped := &SkippedError{}
+	for _, a := range attachments {
+		if err := ch.AddAttachment(a, i.Member.User.ID); err != nil {
+			if len(attachments) == 1 {
+				return err
+			}
+			ch.lg.Error("Error adding attachment: ", err)
+			skipped.Add(a.Filename, err)
+		}
 	}
 
-	attachmentID, _ := i.ApplicationCommandData().Options[0].Value.(string)
-	attachmentURL := i.ApplicationCommandData().Resolved.Attachments[attachmentID].URL
-	attachmentName := i.ApplicationCommandData().Resolved.Attachments[attachmentID].Filename
+	skipped.Added = len(attachments) - len(skipped.Reasons)
 
-	ch.lg.Info("Downloading attachment: %s", attachmentName)
+	if skipped.Added == 0 {
+		return fmt.Errorf("no files could be added: %w", skipped)
+	}
+	if len(skipped.Reasons) > 0 {
+		return skipped
+	}
+	return nil
+}
 
-	song, err := downloadAttachment(attachmentURL)
+// AddAttachment downloads an attached file and queues it.
+func (ch *CommandHandler) AddAttachment(a *discordgo.MessageAttachment, requester string) error {
+	ch.lg.Info("Downloading attachment: %s", a.Filename)
+
+	song, err := downloadAttachment(a, ch.encoding())
 	if err != nil {
-		return fmt.Errorf("Error downloading attachment: %w", err)
+		return err
 	}
 
-	ch.AppendSong(song)
+	song.requester = requester
+
+	err = ch.checkContent(SearchResult{Title: song.title, Duration: song.duration})
+	if err == nil {
+		err = ch.EnqueueSong(song)
+	}
+	if err != nil {
+		ch.discardAttachment(song)
+		return err
+	}
 
-	ch.Success(s, i, "Added to queue")
 	ch.lg.Info("Added song to queue: %s", song.title)
 
 	return nil
 }
 
+// discardAttachment deletes the files of a rejected attachment, unless the
+// same file is queued already.
+func (ch *CommandHandler) discardAttachment(song *Song) {
+	base := attachmentBase(song.sourcePath)
+
+	ch.mu.RLock()
+	queued := slices.ContainsFunc(ch.queue, func(s *Song) bool {
+		return s.sourcePath != "" && attachmentBase(s.sourcePath) == base
+	})
+	ch.mu.RUnlock()
+
+	if !queued {
+		removeAttachmentFiles(song.sourcePath)
+	}
+}
+
 func (ch *CommandHandler) HandleYouTubeURL(_ *discordgo.Session, i *discordgo.InteractionCreate) error {
-	songURL := i.ApplicationCommandData().Options[0].StringValue()
+	return ch.AddURL(i.ApplicationCommandData().Options[0].StringValue(), i.Member.User.ID)
+}
+
+// AddURL adds songs from a YouTube link, or a live stream or radio from any
+// other URL.
+func (ch *CommandHandler) AddURL(songURL, requester string) error {
+	u, err := url.Parse(songURL)
+	if err != nil {
+		return fmt.Errorf("Error parsing URL: %w", err)
+	}
+
+	if IsYouTubeURL(u) {
+		return ch.AddYouTubeURL(songURL, requester)
+	}
+
+	title, err := ch.AddStream(songURL, requester)
+	if err != nil {
+		return fmt.Errorf("Error adding stream: %w", err)
+	}
+
+	ch.lg.Info("Successfully added stream: %s", title)
+
+	return nil
+}
+
+// HandleQuery adds a song from either a YouTube URL or plain search terms,
+// in which case the top search result is used.
+func (ch *CommandHandler) HandleQuery(query, requester string) error {
+	query = strings.TrimSpace(query)
+
+	if IsURL(query) {
+		return ch.AddURL(query, requester)
+	}
+
+	results, err := SearchYouTube(query, 1)
+	if err != nil {
+		return fmt.Errorf("Error searching: %w", err)
+	}
+
+	if len(results) == 0 {
+		return fmt.Errorf("no results for: %s", query)
+	}
+
+	title, err := ch.AddSong(*WatchURL(results[0].ID), results[0].ID, requester)
+	if err != nil {
+		return fmt.Errorf("Error adding song: %w", err)
+	}
+
+	ch.lg.Info("Successfully added: %s", title)
+
+	return nil
+}
+
+func (ch *CommandHandler) AddYouTubeURL(songURL, requester string) error {
 	u, err := url.Parse(songURL)
 	if err != nil {
 		return fmt.Errorf("Error parsing URL: %w", err)
@@ -259,10 +743,14 @@ func (ch *CommandHandler) HandleYouTubeURL(_ *discordgo.Session, i *discordgo.In
 		return fmt.Errorf("Error getting song ID: %w", err)
 	}
 
+	if err = ch.checkPlaylistSize(len(ids)); err != nil {
+		return err
+	}
+
 	if len(ids) == 1 {
 		var title string
 
-		title, err = ch.AddSong(*u, ids[0])
+		title, err = ch.AddSong(*u, ids[0], requester)
 		if err != nil {
 			return fmt.Errorf("Error adding song: %w", err)
 		}
@@ -273,6 +761,8 @@ func (ch *CommandHandler) HandleYouTubeURL(_ *discordgo.Session, i *discordgo.In
 	}
 
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	skipped := &SkippedError{}
 
 	for _, id := range ids {
 		wg.Add(1)
@@ -280,18 +770,30 @@ func (ch *CommandHandler) HandleYouTubeURL(_ *discordgo.Session, i *discordgo.In
 		time.Sleep(200 * time.Millisecond)
 
 		go func() {
-			if _, err = ch.AddSong(*u, id); err != nil {
+			defer wg.Done()
+
+			if _, err := ch.AddSong(*u, id, requester); err != nil {
 				ch.lg.Error("Error adding song: ", err)
+				mu.Lock()
+				skipped.Add(id, err)
+				mu.Unlock()
+				return
 			}
 
 			ch.lg.Info("Added song: %s", id)
-
-			wg.Done()
 		}()
 	}
 	wg.Wait()
 
-	ch.lg.Info("Successfully added: %d songs", len(ids))
+	skipped.Added = len(ids) - len(skipped.Reasons)
 
-	return nil
+	ch.lg.Info("Successfully added: %d songs", skipped.Added)
+
+	if len(skipped.Reasons) == 0 {
+		return nil
+	}
+	if skipped.Added == 0 {
+		return fmt.Errorf("no songs could be added: %w", skipped)
+	}
+	return skipped
 }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type HandlerFunc func(s *discordgo.Session, i *discordgo.InteractionCreate)
+
+// Router dispatches interactions by their type. Commands and autocomplete are
+// matched by command name, components and modals by the longest registered
+// custom ID prefix.
+type Router struct {
+	mu           sync.RWMutex
+	lg           *logger
+	commands     map[string]HandlerFunc
+	autocomplete map[string]HandlerFunc
+	components   map[string]HandlerFunc
+	modals       map[string]HandlerFunc
+}
+
+func NewRouter(logger *logger) *Router {
+	return &Router{
+		lg:           logger,
+		commands:     make(map[string]HandlerFunc),
+		autocomplete: make(map[string]HandlerFunc),
+		components:   make(map[string]HandlerFunc),
+		modals:       make(map[string]HandlerFunc),
+	}
+}
+
+func (r *Router) HandleCommand(name string, h HandlerFunc) {
+	r.mu.Lock()
+	r.commands[name] = h
+	r.mu.Unlock()
+}
+
+func (r *Router) HandleAutocomplete(name string, h HandlerFunc) {
+	r.mu.Lock()
+	r.autocomplete[name] = h
+	r.mu.Unlock()
+}
+
+func (r *Router) HandleComponent(prefix string, h HandlerFunc) {
+	r.mu.Lock()
+	r.components[prefix] = h
+	r.mu.Unlock()
+}
+
+func (r *Router) RemoveComponent(prefix string) {
+	r.mu.Lock()
+	delete(r.components, prefix)
+	r.mu.Unlock()
+}
+
+func (r *Router) HandleModal(prefix string, h HandlerFunc) {
+	r.mu.Lock()
+	r.modals[prefix] = h
+	r.mu.Unlock()
+}
+
+func (r *Router) RemoveModal(prefix string) {
+	r.mu.Lock()
+	delete(r.modals, prefix)
+	r.mu.Unlock()
+}
+
+// Route is the InteractionCreate handler.
+func (r *Router) Route(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer r.recover(s, i)
+
+	var h HandlerFunc
+	var ok bool
+
+	r.mu.RLock()
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		h, ok = r.commands[i.ApplicationCommandData().Name]
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		h, ok = r.autocomplete[i.ApplicationCommandData().Name]
+	case discordgo.InteractionMessageComponent:
+		h, ok = matchPrefix(r.components, i.MessageComponentData().CustomID)
+	case discordgo.InteractionModalSubmit:
+		h, ok = matchPrefix(r.modals, i.ModalSubmitData().CustomID)
+	case discordgo.InteractionPing:
+	}
+	r.mu.RUnlock()
+
+	if !ok {
+		r.unhandled(s, i)
+		return
+	}
+
+	h(s, i)
+}
+
+func matchPrefix(handlers map[string]HandlerFunc, customID string) (HandlerFunc, bool) {
+	var h HandlerFunc
+	best := -1
+
+	for prefix, fn := range handlers {
+		if strings.HasPrefix(customID, prefix) && len(prefix) > best {
+			h = fn
+			best = len(prefix)
+		}
+	}
+
+	return h, best >= 0
+}
+
+func (r *Router) unhandled(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		r.lg.Error("Route: Unknown command: " + i.ApplicationCommandData().Name)
+		r.respondError(s, i, "Unknown command")
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		r.respondChoices(s, i)
+	case discordgo.InteractionMessageComponent, discordgo.InteractionModalSubmit:
+		r.respondError(s, i, "This is no longer active")
+	case discordgo.InteractionPing:
+	}
+}
+
+// recover turns a handler panic into an error message for the user.
+func (r *Router) recover(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+
+	r.lg.Error(fmt.Sprintf("Route: Handler panicked: %v\n%s", rec, debug.Stack()))
+
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		r.respondChoices(s, i)
+		return
+	}
+
+	r.respondError(s, i, "Something went wrong while handling this")
+}
+
+// respondError shows msg to the user whether or not the interaction has
+// already been acknowledged.
+func (r *Router) respondError(s *discordgo.Session, i *discordgo.InteractionCreate, msg string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: msg,
+		},
+	})
+	if err == nil {
+		return
+	}
+
+	_, err = s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+		Flags:   discordgo.MessageFlagsEphemeral,
+		Content: msg,
+	})
+	if err != nil {
+		r.lg.Error("respondError: Error sending followup: ", err)
+	}
+}
+
+func (r *Router) respondChoices(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: []*discordgo.ApplicationCommandOptionChoice{},
+		},
+	})
+	if err != nil {
+		r.lg.Error("respondChoices: Error responding to interaction: ", err)
+	}
+}
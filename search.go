@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	searchPrefix  = "search:"
+	searchResults = 10
+	searchTimeout = time.Minute
+)
+
+func (ch *CommandHandler) handleSearch(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	const op string = "handleSearch: "
+
+	ch.Wait(s, i)
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		ch.lg.Error(op+"Invalid interaction type: ", fmt.Errorf("%v", i.Type))
+		ch.Error(s, i, fmt.Errorf("invalid interaction type: %s", i.Type.String()))
+		return
+	}
+
+	if len(i.ApplicationCommandData().Options) == 0 {
+		ch.lg.Error(op + "No options provided")
+		ch.Error(s, i, errors.New("no options provided"))
+		return
+	}
+
+	query := strings.TrimSpace(i.ApplicationCommandData().Options[0].StringValue())
+
+	results, err := SearchYouTube(query, searchResults)
+	if err != nil {
+		ch.lg.Error(op+"Error searching: ", err)
+		ch.Error(s, i, fmt.Errorf("Error searching: %w", err))
+		return
+	}
+
+	if len(results) == 0 {
+		ch.Error(s, i, fmt.Errorf("no results for: %s", query))
+		return
+	}
+
+	options := make([]discordgo.SelectMenuOption, 0, len(results))
+	b := strings.Builder{}
+
+	for n, r := range results {
+		duration := FormatDuration(r.Duration)
+		b.WriteString(fmt.Sprintf("%d. %s (%s, %s)\n", n+1, r.Title, r.Channel, duration))
+		options = append(options, discordgo.SelectMenuOption{
+			Label:       truncate(fmt.Sprintf("%d. %s", n+1, r.Title), 100),
+			Value:       r.ID,
+			Description: truncate(r.Channel+" | "+duration, 100),
+		})
+	}
+
+	menu := discordgo.SelectMenu{Placeholder: "Pick a song to add", Options: options}
+
+	err = ch.showPicker(s, i, searchPrefix, b.String(), menu, func(s *discordgo.Session, ci *discordgo.InteractionCreate) {
+		ch.handleSearchPick(s, ci, results)
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error sending results: ", err)
+	}
+}
+
+// showPicker replaces the response to i with content and the select menu,
+// and hands the first pick to pick. The menu closes after searchTimeout if
+// nothing was picked.
+func (ch *CommandHandler) showPicker(
+	s *discordgo.Session, i *discordgo.InteractionCreate, prefix, content string,
+	menu discordgo.SelectMenu, pick HandlerFunc,
+) error {
+	customID := prefix + i.ID
+	menu.CustomID = customID
+
+	components := []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{menu}},
+	}
+
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &components,
+	})
+	if err != nil {
+		return fmt.Errorf("error sending picker: %w", err)
+	}
+
+	// Whichever comes first, a pick or the timeout, closes the picker.
+	var once sync.Once
+
+	ch.router.HandleComponent(customID, func(s *discordgo.Session, ci *discordgo.InteractionCreate) {
+		once.Do(func() {
+			ch.router.RemoveComponent(customID)
+			pick(s, ci)
+		})
+	})
+
+	time.AfterFunc(searchTimeout, func() {
+		once.Do(func() {
+			ch.router.RemoveComponent(customID)
+
+			msg := "Timed out, nothing was picked"
+			_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Content:    &msg,
+				Components: &[]discordgo.MessageComponent{},
+			})
+			if err != nil {
+				ch.lg.Error("showPicker: Error closing picker: ", err)
+			}
+		})
+	})
+
+	return nil
+}
+
+func (ch *CommandHandler) handleSearchPick(s *discordgo.Session, i *discordgo.InteractionCreate, results []SearchResult) {
+	const op string = "handleSearchPick: "
+
+	values := i.MessageComponentData().Values
+	if len(values) == 0 {
+		ch.lg.Error(op + "No song picked")
+		return
+	}
+
+	var picked SearchResult
+	for _, r := range results {
+		if r.ID == values[0] {
+			picked = r
+		}
+	}
+
+	if picked.ID == "" {
+		ch.lg.Error(op + "Unknown song picked: " + values[0])
+		ch.Success(s, i, "Unknown song")
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    "Adding: " + picked.Title,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		ch.lg.Error(op+"Error responding to interaction: ", err)
+	}
+
+	title, err := ch.AddSong(*WatchURL(picked.ID), picked.ID, i.Member.User.ID)
+	if err != nil {
+		ch.lg.Error(op+"Error adding song: ", err)
+		ch.Error(s, i, fmt.Errorf("Error adding song: %w", err))
+		return
+	}
+
+	ch.WaitSuccess(s, i, "Added to queue: "+title)
+	ch.lg.Info("Successfully added: %s", title)
+
+	ch.startPlayback(s, i)
+}
+
+// truncate shortens s to at most n runes, as Discord rejects longer labels.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
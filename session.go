@@ -36,6 +36,17 @@ func (ch *CommandHandler) WaitSuccess(s *discordgo.Session, i *discordgo.Interac
 	}
 }
 
+// Followup sends msg as an extra message after the interaction was answered.
+func (ch *CommandHandler) Followup(s *discordgo.Session, i *discordgo.InteractionCreate, msg string) {
+	_, err := s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+		Flags:   discordgo.MessageFlagsEphemeral,
+		Content: msg,
+	})
+	if err != nil {
+		ch.lg.Error("Error sending followup: ", err)
+	}
+}
+
 func (ch *CommandHandler) Wait(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
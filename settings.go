@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const settingsPath = "./settings.json"
+
+const (
+	defaultVolume = 100
+	maxVolume     = 200
+)
+
+// Settings is the per guild configuration changed with /config.
+type Settings struct {
+	// DJRole may use DJCommands and manage songs requested by others.
+	DJRole     string   `json:"dj_role"`
+	DJCommands []string `json:"dj_commands"`
+	// AloneExempt lets anyone alone with the bot in voice use DJCommands.
+	AloneExempt bool `json:"alone_exempt"`
+	// DiscordPermissions hides DJCommands from members without Manage Server
+	// unless allowed in the server's integration settings.
+	DiscordPermissions bool `json:"discord_permissions"`
+
+	// VoteSkip makes /skip a vote unless used by the requester or a DJ. The
+	// song is skipped once VoteRatio of the listeners have voted.
+	VoteSkip  bool    `json:"vote_skip"`
+	VoteRatio float64 `json:"vote_ratio"`
+
+	// FairQueue interleaves upcoming songs round-robin by requester.
+	FairQueue bool `json:"fair_queue"`
+	// MaxUserSongs and MaxUserMinutes limit what a single user can have
+	// queued at once, zero means no limit.
+	MaxUserSongs   int `json:"max_user_songs"`
+	MaxUserMinutes int `json:"max_user_minutes"`
+
+	// Limits on what can be queued at all, zero means no limit.
+	MaxTrackMinutes int `json:"max_track_minutes"`
+	MaxQueueLength  int `json:"max_queue_length"`
+	MaxPlaylistSize int `json:"max_playlist_size"`
+
+	// BlockedChannels holds channel IDs or names, BlockedKeywords are matched
+	// against titles ignoring case.
+	BlockedVideos   []string `json:"blocked_videos"`
+	BlockedChannels []string `json:"blocked_channels"`
+	BlockedKeywords []string `json:"blocked_keywords"`
+
+	// IdleMinutes is how long the bot stays in voice with nothing to play or
+	// nobody listening, zero means forever.
+	IdleMinutes int `json:"idle_minutes"`
+
+	// Volume is in percent, anything but 100 is applied through ffmpeg.
+	Volume int `json:"volume"`
+
+	// Normalize brings every song to LoudnessTarget LUFS.
+	Normalize      bool    `json:"normalize"`
+	LoudnessTarget float64 `json:"loudness_target"`
+
+	// CrossfadeSeconds mixes the end of a song into the next one, zero
+	// plays them back to back.
+	CrossfadeSeconds int `json:"crossfade_seconds"`
+
+	// Bitrate in kb/s, VBR and Application configure the opus encoder.
+	// MatchChannelBitrate uses the voice channel's bitrate instead.
+	Bitrate             int    `json:"bitrate"`
+	VBR                 string `json:"vbr"`
+	Application         string `json:"application"`
+	MatchChannelBitrate bool   `json:"match_channel_bitrate"`
+}
+
+func defaultSettings() Settings {
+	return Settings{
+		DJRole:         DJ,
+		DJCommands:     []string{"clear", "shuffle", "skip", "leave"},
+		AloneExempt:    true,
+		VoteRatio:      0.5,
+		IdleMinutes:    5,
+		Volume:         defaultVolume,
+		LoudnessTarget: defaultLoudnessTarget,
+		Bitrate:        defaultBitrate,
+		VBR:            defaultVBR,
+		Application:    defaultApplication,
+	}
+}
+
+type SettingsStore struct {
+	mu     sync.RWMutex
+	path   string
+	guilds map[string]Settings
+}
+
+func LoadSettings(path string) (*SettingsStore, error) {
+	st := &SettingsStore{path: path, guilds: make(map[string]Settings)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading settings: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing settings: %w", err)
+	}
+
+	// Start from the defaults so settings added later get sensible values.
+	for guildID, msg := range raw {
+		s := defaultSettings()
+		if err = json.Unmarshal(msg, &s); err != nil {
+			return nil, fmt.Errorf("error parsing settings for %s: %w", guildID, err)
+		}
+		st.guilds[guildID] = s
+	}
+
+	return st, nil
+}
+
+func (st *SettingsStore) Get(guildID string) Settings {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	s, ok := st.guilds[guildID]
+	if !ok {
+		return defaultSettings()
+	}
+	return s
+}
+
+// Update applies fn to the guild's settings and saves them.
+func (st *SettingsStore) Update(guildID string, fn func(s *Settings)) (Settings, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.guilds[guildID]
+	if !ok {
+		s = defaultSettings()
+	}
+	fn(&s)
+	st.guilds[guildID] = s
+
+	data, err := json.MarshalIndent(st.guilds, "", "  ")
+	if err != nil {
+		return s, fmt.Errorf("error encoding settings: %w", err)
+	}
+
+	if err = writeFileAtomic(st.path, data); err != nil {
+		return s, fmt.Errorf("error saving settings: %w", err)
+	}
+
+	return s, nil
+}
+
+// writeFileAtomic replaces the file at path with data, so a crash never
+// leaves it half written.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
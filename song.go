@@ -1,55 +1,143 @@
 package main
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sync"
+	"time"
 )
 
 type Song struct {
+	// mu guards loading buffer, which may happen ahead of time.
+	mu        sync.Mutex
 	title     string
 	id        string
 	audioPath string
-	buffer    [][]byte
+	// sourcePath is the original audio the cached frames were encoded from.
+	sourcePath string
+	// mediaURL is where songs that are not YouTube videos came from. Live
+	// songs play it until skipped and have no cache.
+	live     bool
+	mediaURL string
+	// track is the path of a library song within the library.
+	track string
+	// episode is the key of the podcast episode whose position is
+	// bookmarked for guildID, the guild it was queued in.
+	episode string
+	guildID string
+	// loudnessFile is where the loudness of sourcePath is stored. It is only
+	// measured once normalization needs it, see Loudness.
+	loudnessFile string
+	// loudnessOnce runs the measurement, loudnessMu guards its result so
+	// that the player can check for it without waiting.
+	loudnessOnce sync.Once
+	loudnessMu   sync.Mutex
+	loudness     *Loudness
+	measured     bool
+	requester    string
+	// pinned songs were put in place by /playnext or /move and keep their
+	// position when the fair queue reorders. Guarded by the handler's mu.
+	pinned   bool
+	duration time.Duration
+	buffer   [][]byte
+	// offset is how much of the song has been played, which is where
+	// playback continues from.
+	offset time.Duration
 }
 
-func NewSong(title, id, audioPath string) *Song {
-	buffer := make([][]byte, 0)
-	return &Song{title, id, audioPath, buffer}
+func NewSong(title, id, audioPath, requester string, duration time.Duration) *Song {
+	return &Song{
+		title:     title,
+		id:        id,
+		audioPath: audioPath,
+		requester: requester,
+		duration:  duration,
+		buffer:    make([][]byte, 0),
+	}
+}
+
+// Loudness loads or measures the song's loudness the first time it is
+// called, which can take a while. It returns nil from then on if that failed.
+func (s *Song) Loudness() (*Loudness, error) {
+	var err error
+	s.loudnessOnce.Do(func() {
+		var l *Loudness
+		if s.loudnessFile == "" {
+			err = errNoSource
+		} else {
+			l, err = storedLoudness(s.sourcePath, s.loudnessFile)
+		}
+
+		s.loudnessMu.Lock()
+		s.loudness, s.measured = l, true
+		s.loudnessMu.Unlock()
+	})
+
+	l, _ := s.MeasuredLoudness()
+	return l, err
+}
+
+// MeasuredLoudness returns the song's loudness without waiting, and false if
+// Loudness has not finished yet.
+func (s *Song) MeasuredLoudness() (*Loudness, bool) {
+	s.loudnessMu.Lock()
+	defer s.loudnessMu.Unlock()
+
+	return s.loudness, s.measured
+}
+
+// Source describes where the song came from.
+func (s *Song) Source() string {
+	if s.mediaURL != "" {
+		return s.mediaURL
+	}
+	if s.track != "" {
+		return "library: " + s.track
+	}
+	if s.id == "" {
+		return "attachment"
+	}
+	return WatchURL(s.id).String()
 }
 
 func (s *Song) LoadSound() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.live || len(s.buffer) > 0 {
+		return nil
+	}
+
 	file, err := os.Open(s.audioPath)
 	if err != nil {
 		return fmt.Errorf("error opening file: %w", err)
 	}
-
-	var opuslen int16
+	defer file.Close()
 
 	for {
-		err = binary.Read(file, binary.LittleEndian, &opuslen)
-
-		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
-			err = file.Close()
-			if err != nil {
-				return fmt.Errorf("error closing file: %w", err)
-			}
+		frame, err := readFrame(file)
+		if errors.Is(err, io.EOF) {
 			return nil
 		}
-
 		if err != nil {
 			return fmt.Errorf("Error reading file: %w", err)
 		}
 
-		inBuf := make([]byte, opuslen)
-		err = binary.Read(file, binary.LittleEndian, &inBuf)
-
-		if err != nil {
-			return fmt.Errorf("Error reading file: %w", err)
-		}
+		s.buffer = append(s.buffer, frame)
+	}
+}
 
-		s.buffer = append(s.buffer, inBuf)
+// Length formats the duration of the song, or LIVE for streams.
+func (s *Song) Length() string {
+	if s.live {
+		return "LIVE"
 	}
+	return FormatDuration(s.duration)
+}
+
+// Elapsed is how far into the song playback is.
+func (s *Song) Elapsed() time.Duration {
+	return s.offset
 }
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frameDuration is how much audio one opus frame holds.
+const frameDuration = 20 * time.Millisecond
+
+// frameSource hands the player one opus frame at a time and returns io.EOF
+// once the song is over. Step is how much of the song one frame covers.
+type frameSource interface {
+	Next() ([]byte, error)
+	Step() time.Duration
+	Close() error
+}
+
+// bufferSource plays the frames cached in song.buffer as they are.
+type bufferSource struct {
+	song *Song
+}
+
+func (b *bufferSource) Next() ([]byte, error) {
+	n := int(b.song.offset / frameDuration)
+	if n >= len(b.song.buffer) {
+		return nil, io.EOF
+	}
+	return b.song.buffer[n], nil
+}
+
+func (b *bufferSource) Step() time.Duration {
+	return frameDuration
+}
+
+func (b *bufferSource) Close() error {
+	return nil
+}
+
+// pipeSource plays frames encoded on the fly by ffmpeg, so that filters
+// such as the volume can change while a song plays.
+type pipeSource struct {
+	ffmpeg *exec.Cmd
+	out    *oggReader
+	step   time.Duration
+}
+
+// startPipeline starts encoding input from start through the ffmpeg filters.
+func startPipeline(input string, start time.Duration, filters []string, speed float64, e Encoding) (*pipeSource, error) {
+	args := append(seekArgs(start), "-i", input)
+	if len(filters) > 0 {
+		args = append(args, "-af", strings.Join(filters, ","))
+	}
+
+	return startEncoder(args, speed, e)
+}
+
+// startCrossfade encodes the rest of from, starting at its current offset,
+// mixed over fade into the start of to.
+func (ch *CommandHandler) startCrossfade(from, to *Song, fade time.Duration) (*pipeSource, error) {
+	for _, song := range []*Song{from, to} {
+		if _, err := os.Stat(song.sourcePath); song.sourcePath == "" || err != nil {
+			return nil, fmt.Errorf("%w: %s", errNoSource, song.title)
+		}
+	}
+
+	chain := func(song *Song) string {
+		filters := ch.audioFilters(song)
+		if len(filters) == 0 {
+			return "anull"
+		}
+		return strings.Join(filters, ",")
+	}
+
+	graph := fmt.Sprintf("[0:a]%s[a];[1:a]%s[b];[a][b]acrossfade=d=%.3f",
+		chain(from), chain(to), fade.Seconds())
+
+	args := append(seekArgs(from.Elapsed()), "-i", from.sourcePath, "-i", to.sourcePath, "-filter_complex", graph)
+
+	return startEncoder(args, ch.Filter().Speed(), ch.encoding())
+}
+
+func seekArgs(start time.Duration) []string {
+	if start <= 0 {
+		return nil
+	}
+	return []string{"-ss", strconv.FormatFloat(start.Seconds(), 'f', 3, 64)}
+}
+
+// startEncoder runs ffmpeg with the input args, encoding with e. speed is
+// how fast the output plays compared to the input.
+func startEncoder(input []string, speed float64, e Encoding) (*pipeSource, error) {
+	args := append([]string{"-v", "error"}, input...)
+	args = append(args, e.args()...)
+
+	ffmpeg := exec.Command("ffmpeg", args...)
+
+	out, err := ffmpeg.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error creating ffmpeg pipe: %w", err)
+	}
+
+	if err = ffmpeg.Start(); err != nil {
+		return nil, fmt.Errorf("error starting ffmpeg: %w", err)
+	}
+
+	step := time.Duration(float64(frameDuration) * speed)
+
+	return &pipeSource{ffmpeg: ffmpeg, out: newOggReader(out), step: step}, nil
+}
+
+func (p *pipeSource) Next() ([]byte, error) {
+	return p.out.Next()
+}
+
+func (p *pipeSource) Step() time.Duration {
+	return p.step
+}
+
+func (p *pipeSource) Close() error {
+	// ffmpeg may have exited already at the end of the song.
+	_ = p.ffmpeg.Process.Kill()
+	_ = p.ffmpeg.Wait()
+	return nil
+}
+
+// readFrame reads one length prefixed opus frame in the dca format.
+func readFrame(r io.Reader) ([]byte, error) {
+	var opuslen int16
+
+	err := binary.Read(r, binary.LittleEndian, &opuslen)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading frame length: %w", err)
+	}
+
+	frame := make([]byte, opuslen)
+	if _, err = io.ReadFull(r, frame); err != nil {
+		return nil, fmt.Errorf("error reading frame: %w", err)
+	}
+
+	return frame, nil
+}
+
+// openSource decides how song is played: straight from its cached frames, or
+// through ffmpeg when the audio has to be processed on the way.
+func (ch *CommandHandler) openSource(song *Song) (frameSource, error) {
+	filters := ch.audioFilters(song)
+
+	if song.live {
+		src, err := startStream(song, filters, ch.encoding())
+		if err != nil {
+			return nil, fmt.Errorf("error starting stream: %w", err)
+		}
+		return src, nil
+	}
+
+	if len(filters) == 0 {
+		return &bufferSource{song}, nil
+	}
+
+	if !hasSource(song) {
+		// Songs cached before sources were kept can only play as they are,
+		// see sourceNote.
+		ch.lg.Info("No source audio for %s, playing it unprocessed", song.title)
+		return &bufferSource{song}, nil
+	}
+
+	src, err := startPipeline(song.sourcePath, song.Elapsed(), filters, ch.Filter().Speed(), ch.encoding())
+	if err != nil {
+		return nil, fmt.Errorf("error starting audio pipeline: %w", err)
+	}
+
+	return src, nil
+}
+
+// startSource opens song's source, falling back to the cached frames when
+// the pipeline cannot start.
+func (ch *CommandHandler) startSource(song *Song) frameSource {
+	src, err := ch.openSource(song)
+	if err != nil {
+		ch.lg.Error("startSource: Error opening source: ", err)
+		return &bufferSource{song}
+	}
+	return src
+}
+
+// audioFilters returns the ffmpeg filters the guild's settings ask for.
+func (ch *CommandHandler) audioFilters(song *Song) []string {
+	settings := ch.settings.Get(GUILD)
+
+	filters := make([]string, 0)
+	if l := ch.songLoudness(song, settings); l != nil {
+		if gain := l.Gain(settings.LoudnessTarget); gain != 0 {
+			filters = append(filters, fmt.Sprintf("volume=%.2fdB", gain))
+		}
+	}
+	if settings.Volume != defaultVolume {
+		filters = append(filters, fmt.Sprintf("volume=%.2f", float64(settings.Volume)/100))
+	}
+	filters = append(filters, ch.Filter().filters()...)
+
+	return filters
+}
+
+// hasSource reports whether song's audio can be processed while it plays.
+func hasSource(song *Song) bool {
+	if song.live {
+		return true
+	}
+	if song.sourcePath == "" {
+		return false
+	}
+	_, err := os.Stat(song.sourcePath)
+	return err == nil
+}
+
+// sourceNote tells the user that the current song ignores changed audio
+// settings, or returns "" if it picks them up.
+func (ch *CommandHandler) sourceNote() string {
+	song := ch.GetCurrentSong()
+	if song == nil || hasSource(song) {
+		return ""
+	}
+	return fmt.Sprintf("\n%s was cached without its source audio and plays unchanged, the next songs will use this", song.title)
+}
+
+// songLoudness returns song's loudness if normalization is on and it has
+// been measured. It never waits for a measurement, songs that are not
+// measured yet or that it fails for play unnormalized.
+func (ch *CommandHandler) songLoudness(song *Song, settings Settings) *Loudness {
+	if !settings.Normalize {
+		return nil
+	}
+
+	l, ok := song.MeasuredLoudness()
+	if !ok {
+		ch.measureAhead(song)
+	}
+	return l
+}
+
+// measureSlots limits how many songs are measured at once, so that a queued
+// playlist does not start an ffmpeg for every song.
+var measureSlots = make(chan struct{}, 2)
+
+// measureAhead measures song's loudness in the background, so that it is
+// ready by the time the song plays. It does nothing with normalization off.
+func (ch *CommandHandler) measureAhead(song *Song) {
+	if !ch.settings.Get(GUILD).Normalize {
+		return
+	}
+
+	go func() {
+		measureSlots <- struct{}{}
+		defer func() { <-measureSlots }()
+
+		_, err := song.Loudness()
+		if err != nil && !errors.Is(err, errNoSource) {
+			ch.lg.Error("measureAhead: Error measuring loudness of "+song.title+": ", err)
+		}
+	}()
+}
+
+// RestartSource makes the player pick up changed audio settings, continuing
+// from the current position.
+func (ch *CommandHandler) RestartSource() {
+	select {
+	case ch.restartChan <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	sendTimeout      = time.Second
+	maxStalls        = 5
+	joinTimeout      = 10 * time.Second
+	maxReconnects    = 5
+	reconnectBackoff = time.Second
+)
+
+var (
+	errVoiceDead    = errors.New("voice connection closed")
+	errVoiceStalled = errors.New("voice connection stalled")
+	errVoiceLeft    = errors.New("left the voice channel")
+)
+
+// userChannel returns the voice channel userID is in, or "" if none.
+func (ch *CommandHandler) userChannel(guildID, userID string) string {
+	vs, err := ch.session.State.VoiceState(guildID, userID)
+	if err != nil {
+		return ""
+	}
+	return vs.ChannelID
+}
+
+// botChannel returns the voice channel the bot is in, or "" if none.
+func (ch *CommandHandler) botChannel(guildID string) string {
+	return ch.userChannel(guildID, ch.session.State.User.ID)
+}
+
+// listeners returns the IDs of everyone but bots in channelID.
+func (ch *CommandHandler) listeners(guildID, channelID string) []string {
+	g, err := ch.session.State.Guild(guildID)
+	if err != nil || channelID == "" {
+		return nil
+	}
+
+	ids := make([]string, 0)
+	for _, vs := range g.VoiceStates {
+		if vs.ChannelID != channelID || isBot(ch.session, guildID, vs) {
+			continue
+		}
+		ids = append(ids, vs.UserID)
+	}
+
+	return ids
+}
+
+// occupants counts everyone in channelID, bots included.
+func (ch *CommandHandler) occupants(guildID, channelID string) int {
+	g, err := ch.session.State.Guild(guildID)
+	if err != nil {
+		return 0
+	}
+
+	n := 0
+	for _, vs := range g.VoiceStates {
+		if vs.ChannelID == channelID {
+			n++
+		}
+	}
+
+	return n
+}
+
+func isBot(s *discordgo.Session, guildID string, vs *discordgo.VoiceState) bool {
+	if vs.UserID == s.State.User.ID {
+		return true
+	}
+
+	m := vs.Member
+	if m == nil {
+		m, _ = s.State.Member(guildID, vs.UserID)
+	}
+
+	return m != nil && m.User != nil && m.User.Bot
+}
+
+// aloneWithBot reports whether userID is the only listener in the bot's
+// voice channel.
+func (ch *CommandHandler) aloneWithBot(guildID, userID string) bool {
+	channelID := ch.botChannel(guildID)
+	if channelID == "" {
+		return false
+	}
+
+	ids := ch.listeners(guildID, channelID)
+	return len(ids) == 1 && ids[0] == userID
+}
+
+// sendFrame sends one opus frame, giving up if the connection dies or stops
+// taking frames.
+func sendFrame(vc *discordgo.VoiceConnection, frame []byte) error {
+	// OpusSend is closed shortly after Dead, check Dead first so we never
+	// send on a closed channel.
+	if isDead(vc) {
+		return errVoiceDead
+	}
+
+	timer := time.NewTimer(sendTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-vc.Dead:
+		return errVoiceDead
+	case vc.OpusSend <- frame:
+		return nil
+	case <-timer.C:
+		return errVoiceStalled
+	}
+}
+
+func isDead(vc *discordgo.VoiceConnection) bool {
+	select {
+	case <-vc.Dead:
+		return true
+	default:
+		return false
+	}
+}
+
+// recoverVoice replaces a dead or stalled voice connection and gets it ready
+// to continue playback.
+func (ch *CommandHandler) recoverVoice(old *discordgo.VoiceConnection, cause error) (*discordgo.VoiceConnection, error) {
+	ch.lg.Error("recoverVoice: Voice connection lost, reconnecting: ", cause)
+
+	vc, err := ch.reconnect(old)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = vc.Speaking(true); err != nil {
+		return nil, fmt.Errorf("error starting speaking: %w", err)
+	}
+
+	ch.lg.Info("Reconnected to voice channel")
+	return vc, nil
+}
+
+// reconnect joins the last known voice channel again with exponential
+// backoff. Once old is no longer the active connection it takes over the one
+// /join made in its place, as happens when the bot is moved while paused, or
+// gives up if there is none because the bot left on purpose.
+func (ch *CommandHandler) reconnect(old *discordgo.VoiceConnection) (*discordgo.VoiceConnection, error) {
+	old.Kill()
+
+	backoff := reconnectBackoff
+	var err error
+
+	for attempt := 1; attempt <= maxReconnects; attempt++ {
+		ch.mu.RLock()
+		active := ch.voiceConn
+		channelID := ch.voiceChan
+		ch.mu.RUnlock()
+
+		if active != old {
+			if active != nil && !isDead(active) {
+				return active, nil
+			}
+			return nil, errVoiceLeft
+		}
+
+		ctx, cancel := context.WithTimeout(ch.ctx, joinTimeout)
+		var vc *discordgo.VoiceConnection
+		vc, err = ch.session.ChannelVoiceJoin(ctx, GUILD, channelID, false, false)
+		cancel()
+
+		if err == nil {
+			ch.mu.Lock()
+			if ch.voiceConn != old {
+				ch.mu.Unlock()
+				return nil, errVoiceLeft
+			}
+			ch.voiceConn = vc
+			ch.mu.Unlock()
+			return vc, nil
+		}
+
+		ch.lg.Error(fmt.Sprintf("reconnect: Attempt %d/%d failed: ", attempt, maxReconnects), err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ch.ctx.Done():
+			return nil, ch.ctx.Err()
+		}
+		backoff *= 2
+	}
+
+	return nil, fmt.Errorf("gave up after %d attempts: %w", maxReconnects, err)
+}
+
+// lostVoice stops the player after the voice connection could not be
+// recovered. The song stays at the head of the queue and continues from
+// where it stopped once the bot joins again.
+func (ch *CommandHandler) lostVoice(song *Song, cause error) {
+	ch.isSpeaking = false
+	ch.finishSong(song, false)
+
+	if errors.Is(cause, errVoiceLeft) {
+		return
+	}
+
+	ch.lg.Error("lostVoice: Could not recover voice connection: ", cause)
+
+	ch.mu.Lock()
+	ch.voiceConn = nil
+	ch.inVC = false
+	ch.autoPaused = false
+	ch.mu.Unlock()
+
+	ch.disarmIdle()
+	ch.RemovePanel()
+	ch.notify(fmt.Sprintf("Lost the voice connection (%v), use /join to continue", cause))
+}
+
+// notify sends msg to the channel playback was started from.
+func (ch *CommandHandler) notify(msg string) {
+	ch.mu.RLock()
+	textChan := ch.textChan
+	ch.mu.RUnlock()
+
+	if textChan == "" {
+		return
+	}
+
+	if _, err := ch.session.ChannelMessageSend(textChan, msg); err != nil {
+		ch.lg.Error("notify: Error sending message: ", err)
+	}
+}
+
+// trackBotVoice follows the bot's own voice state, so that a reconnect goes
+// to the channel it was last moved to.
+func (ch *CommandHandler) trackBotVoice(v *discordgo.VoiceStateUpdate) {
+	if v.UserID != ch.session.State.User.ID {
+		return
+	}
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	switch {
+	case v.ChannelID == "":
+		if ch.voiceConn != nil {
+			ch.lg.Info("Disconnected from voice channel")
+		}
+		if ch.current == nil && ch.voiceConn != nil && isDead(ch.voiceConn) {
+			// Nothing is playing to notice the dead connection.
+			ch.voiceConn = nil
+			ch.inVC = false
+		}
+	case v.ChannelID != ch.voiceChan:
+		ch.lg.Info("Moved to voice channel %s", v.ChannelID)
+		ch.voiceChan = v.ChannelID
+	}
+}
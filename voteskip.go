@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// VoteSkip records userID's vote to skip the current song and skips it once
+// enough listeners in the bot's voice channel agree. It returns the number of
+// votes and how many are needed.
+func (ch *CommandHandler) VoteSkip(userID string) (int, int, error) {
+	listeners := ch.listeners(GUILD, ch.botChannel(GUILD))
+	if !slices.Contains(listeners, userID) {
+		return 0, 0, errors.New("you need to be in the voice channel to vote")
+	}
+
+	ratio := ch.settings.Get(GUILD).VoteRatio
+	needed := max(1, int(math.Ceil(ratio*float64(len(listeners)))))
+
+	ch.mu.Lock()
+	if ch.current == nil {
+		ch.mu.Unlock()
+		return 0, 0, errors.New("nothing is playing")
+	}
+
+	ch.votes[userID] = true
+
+	// Only count voters that are still listening.
+	votes := 0
+	for _, id := range listeners {
+		if ch.votes[id] {
+			votes++
+		}
+	}
+	ch.mu.Unlock()
+
+	if votes >= needed {
+		ch.SkipSong()
+	}
+
+	return votes, needed, nil
+}
+
+// voteSkipMessage votes on behalf of the interaction's member and describes
+// the outcome.
+func (ch *CommandHandler) voteSkipMessage(i *discordgo.InteractionCreate) (string, error) {
+	votes, needed, err := ch.VoteSkip(i.Member.User.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if votes >= needed {
+		ch.lg.Info("Vote skip passed with %d/%d votes", votes, needed)
+		return fmt.Sprintf("Vote passed (%d/%d), skipped", votes, needed), nil
+	}
+
+	return fmt.Sprintf("Voted to skip (%d/%d)", votes, needed), nil
+}